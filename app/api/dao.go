@@ -0,0 +1,35 @@
+package api
+
+import (
+	"k0pern1cus/app/client/twitch"
+	"time"
+)
+
+// NowPlayingResponse represents the clip that is currently on air
+type NowPlayingResponse struct {
+	Playing   bool         `json:"playing"`
+	Paused    bool         `json:"paused"`
+	Clip      *twitch.Clip `json:"clip,omitempty"`
+	StartedAt *time.Time   `json:"started_at,omitempty"`
+}
+
+// QueueResponse represents the preloaded clips waiting to be played
+type QueueResponse struct {
+	Clips []twitch.Clip `json:"clips"`
+}
+
+// CatalogResponse represents the clips that are left in rotation
+type CatalogResponse struct {
+	Count    int     `json:"count"`
+	Duration float64 `json:"duration"`
+}
+
+// StatusResponse represents the result of a control action
+type StatusResponse struct {
+	OK bool `json:"ok"`
+}
+
+// ErrorResponse represents an API error
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/app/service/clips"
+	"k0pern1cus/app/service/streamer"
+	"k0pern1cus/pkg/config"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/do"
+)
+
+var shutdownTimeout = 5 * time.Second
+
+// controller is the part of the streamer the API controls, the tests use a fake one
+type controller interface {
+	NowPlaying() (twitch.Clip, time.Time, bool)
+	Queue() []twitch.Clip
+	Skip() bool
+	Pause() error
+	Resume()
+	IsPaused() bool
+}
+
+type Server struct {
+	cfg             *config.Config
+	clipsService    *clips.Service
+	streamerService controller
+
+	app *fiber.App
+}
+
+func New(di *do.Injector) (*Server, error) {
+	return newServer(
+		do.MustInvoke[*config.Config](di),
+		do.MustInvoke[*clips.Service](di),
+		do.MustInvoke[*streamer.Service](di),
+	), nil
+}
+
+func newServer(cfg *config.Config, clipsService *clips.Service, streamerService controller) *Server {
+	s := &Server{
+		cfg:             cfg,
+		clipsService:    clipsService,
+		streamerService: streamerService,
+		app: fiber.New(fiber.Config{
+			DisableStartupMessage: true,
+		}),
+	}
+
+	group := s.app.Group("/api", s.authMiddleware)
+	group.Get("/now-playing", s.handleNowPlaying)
+	group.Get("/queue", s.handleQueue)
+	group.Get("/catalog", s.handleCatalog)
+	group.Post("/skip", s.handleSkip)
+	group.Post("/pause", s.handlePause)
+	group.Post("/resume", s.handleResume)
+
+	return s
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		_ = s.app.ShutdownWithContext(shutdownCtx)
+	}()
+
+	slog.Info("Starting API server",
+		slog.String("listen", s.cfg.API.Listen),
+	)
+
+	if err := s.app.Listen(s.cfg.API.Listen); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) authMiddleware(c *fiber.Ctx) error {
+	if s.cfg.API.Token == "" {
+		return c.Next()
+	}
+
+	token := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.API.Token)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{Error: "unauthorized"})
+	}
+
+	return c.Next()
+}
+
+func (s *Server) handleNowPlaying(c *fiber.Ctx) error {
+	resp := NowPlayingResponse{
+		Paused: s.streamerService.IsPaused(),
+	}
+
+	if clip, startedAt, ok := s.streamerService.NowPlaying(); ok {
+		resp.Playing = true
+		resp.Clip = &clip
+		resp.StartedAt = &startedAt
+	}
+
+	return c.JSON(resp)
+}
+
+func (s *Server) handleQueue(c *fiber.Ctx) error {
+	return c.JSON(QueueResponse{
+		Clips: s.streamerService.Queue(),
+	})
+}
+
+func (s *Server) handleCatalog(c *fiber.Ctx) error {
+	count, duration := s.clipsService.Stats()
+
+	return c.JSON(CatalogResponse{
+		Count:    count,
+		Duration: duration,
+	})
+}
+
+func (s *Server) handleSkip(c *fiber.Ctx) error {
+	if !s.streamerService.Skip() {
+		return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: "nothing is playing"})
+	}
+
+	return c.JSON(StatusResponse{OK: true})
+}
+
+func (s *Server) handlePause(c *fiber.Ctx) error {
+	if err := s.streamerService.Pause(); err != nil {
+		if errors.Is(err, streamer.ErrPauseUnavailable) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{Error: err.Error()})
+		}
+
+		return err
+	}
+
+	return c.JSON(StatusResponse{OK: true})
+}
+
+func (s *Server) handleResume(c *fiber.Ctx) error {
+	s.streamerService.Resume()
+
+	return c.JSON(StatusResponse{OK: true})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/app/service/streamer"
+	"k0pern1cus/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeController struct {
+	playing    bool
+	paused     bool
+	pauseErr   error
+	skipCalled bool
+}
+
+func (f *fakeController) NowPlaying() (twitch.Clip, time.Time, bool) {
+	return twitch.Clip{}, time.Time{}, false
+}
+
+func (f *fakeController) Queue() []twitch.Clip {
+	return nil
+}
+
+func (f *fakeController) Skip() bool {
+	f.skipCalled = true
+	return f.playing
+}
+
+func (f *fakeController) Pause() error {
+	if f.pauseErr != nil {
+		return f.pauseErr
+	}
+
+	f.paused = true
+	return nil
+}
+
+func (f *fakeController) Resume() {
+	f.paused = false
+}
+
+func (f *fakeController) IsPaused() bool {
+	return f.paused
+}
+
+func newTestServer(token string, controller *fakeController) *Server {
+	cfg := &config.Config{}
+	cfg.API.Token = token
+
+	return newServer(cfg, nil, controller)
+}
+
+func doRequest(t *testing.T, s *Server, method, path, token string) (int, map[string]any) {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := s.app.Test(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+
+	return res.StatusCode, body
+}
+
+func TestAuth(t *testing.T) {
+	controller := &fakeController{}
+	s := newTestServer("secret", controller)
+
+	status, body := doRequest(t, s, http.MethodPost, "/api/skip", "")
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.Equal(t, "unauthorized", body["error"])
+
+	status, _ = doRequest(t, s, http.MethodPost, "/api/skip", "wrong")
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.False(t, controller.skipCalled)
+
+	status, _ = doRequest(t, s, http.MethodGet, "/api/now-playing", "secret")
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestSkip(t *testing.T) {
+	controller := &fakeController{}
+	s := newTestServer("", controller)
+
+	status, body := doRequest(t, s, http.MethodPost, "/api/skip", "")
+	require.Equal(t, http.StatusConflict, status)
+	require.Equal(t, "nothing is playing", body["error"])
+
+	controller.playing = true
+
+	status, body = doRequest(t, s, http.MethodPost, "/api/skip", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, true, body["ok"])
+}
+
+func TestPauseResume(t *testing.T) {
+	controller := &fakeController{}
+	s := newTestServer("", controller)
+
+	status, _ := doRequest(t, s, http.MethodPost, "/api/pause", "")
+	require.Equal(t, http.StatusOK, status)
+
+	status, body := doRequest(t, s, http.MethodGet, "/api/now-playing", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, true, body["paused"])
+
+	status, _ = doRequest(t, s, http.MethodPost, "/api/resume", "")
+	require.Equal(t, http.StatusOK, status)
+	require.False(t, controller.paused)
+}
+
+func TestPauseWithoutSlate(t *testing.T) {
+	controller := &fakeController{pauseErr: streamer.ErrPauseUnavailable}
+	s := newTestServer("", controller)
+
+	status, body := doRequest(t, s, http.MethodPost, "/api/pause", "")
+	require.Equal(t, http.StatusConflict, status)
+	require.Equal(t, streamer.ErrPauseUnavailable.Error(), body["error"])
+	require.False(t, controller.paused)
+}
@@ -3,9 +3,11 @@ package twitch
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"k0pern1cus/pkg/config"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
@@ -18,6 +20,7 @@ import (
 
 var tokenRefreshInterval = 10 * time.Minute
 var baseURL = "https://api.twitch.tv/helix"
+var authURL = "https://id.twitch.tv/oauth2/token"
 
 type Client struct {
 	cfg        *config.Config
@@ -26,12 +29,17 @@ type Client struct {
 	mutex       sync.RWMutex
 	authToken   string
 	tokenExpiry time.Time
+	gameNames   map[string]string
+
+	limiter *RateLimiter
 }
 
 func NewClient(di *do.Injector) (*Client, error) {
 	return &Client{
 		cfg:        do.MustInvoke[*config.Config](di),
 		httpClient: &http.Client{Timeout: 30 * time.Second},
+		gameNames:  make(map[string]string),
+		limiter:    newRateLimiter(),
 	}, nil
 }
 
@@ -73,38 +81,243 @@ func (c *Client) GetClips(ctx context.Context, params *GetClipsParams) (*ClipsRe
 		queryParams.Add("ended_at", params.EndedAt.Format(time.RFC3339))
 	}
 
-	requestURL := fmt.Sprintf("%s/clips?%s", baseURL, queryParams.Encode())
+	var clipsResponse ClipsResponse
+	if err := c.get(ctx, "clips", queryParams, &clipsResponse); err != nil {
+		return nil, err
+	}
+
+	return &clipsResponse, nil
+}
+
+func (c *Client) GetGames(ctx context.Context, params *GetGamesParams) (*GamesResponse, error) {
+	span := sentry.StartSpan(ctx, "twitch.get_games")
+	defer span.Finish()
+
+	if err := c.ensureAuthenticated(ctx); err != nil {
+		sentry.CaptureException(err)
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	queryParams := url.Values{}
+
+	for _, id := range params.IDs {
+		queryParams.Add("id", id)
+	}
+	for _, name := range params.Names {
+		queryParams.Add("name", name)
+	}
+
+	var gamesResponse GamesResponse
+	if err := c.get(ctx, "games", queryParams, &gamesResponse); err != nil {
+		return nil, err
+	}
+
+	return &gamesResponse, nil
+}
+
+func (c *Client) GetUsers(ctx context.Context, params *GetUsersParams) (*UsersResponse, error) {
+	span := sentry.StartSpan(ctx, "twitch.get_users")
+	defer span.Finish()
+
+	if err := c.ensureAuthenticated(ctx); err != nil {
+		sentry.CaptureException(err)
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	queryParams := url.Values{}
+
+	for _, id := range params.IDs {
+		queryParams.Add("id", id)
+	}
+	for _, login := range params.Logins {
+		queryParams.Add("login", login)
+	}
+
+	var usersResponse UsersResponse
+	if err := c.get(ctx, "users", queryParams, &usersResponse); err != nil {
+		return nil, err
+	}
+
+	return &usersResponse, nil
+}
+
+// SearchCategories looks games and categories up by a partial name
+func (c *Client) SearchCategories(ctx context.Context, params *SearchCategoriesParams) (*CategoriesResponse, error) {
+	span := sentry.StartSpan(ctx, "twitch.search_categories")
+	defer span.Finish()
+
+	if err := c.ensureAuthenticated(ctx); err != nil {
+		sentry.CaptureException(err)
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	queryParams := url.Values{}
+	queryParams.Add("query", params.Query)
+
+	if params.First > 0 {
+		queryParams.Add("first", fmt.Sprintf("%d", params.First))
+	}
+
+	var categoriesResponse CategoriesResponse
+	if err := c.get(ctx, "search/categories", queryParams, &categoriesResponse); err != nil {
+		return nil, err
+	}
+
+	return &categoriesResponse, nil
+}
+
+// GetGameName returns the name of the game, names are cached for the lifetime of the client
+func (c *Client) GetGameName(ctx context.Context, gameID string) (string, error) {
+	c.mutex.RLock()
+	name, ok := c.gameNames[gameID]
+	c.mutex.RUnlock()
+
+	if ok {
+		return name, nil
+	}
+
+	res, err := c.GetGames(ctx, &GetGamesParams{
+		IDs: []string{gameID},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(res.Data) == 0 {
+		return "", fmt.Errorf("game not found: %s", gameID)
+	}
+
+	c.mutex.Lock()
+	c.gameNames[gameID] = res.Data[0].Name
+	c.mutex.Unlock()
+
+	return res.Data[0].Name, nil
+}
+
+// get sends the request through the shared rate limiter, waits for the bucket reset on 429
+// and retries network failures and 5xx responses with a jittered backoff
+func (c *Client) get(ctx context.Context, endpoint string, queryParams url.Values, result any) error {
+	requestURL := fmt.Sprintf("%s/%s?%s", baseURL, endpoint, queryParams.Encode())
+
+	var lastErr error
+	var reauthenticated, skipBackoff bool
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 && !skipBackoff {
+			if err := c.waitRetry(ctx, attempt); err != nil {
+				return err
+			}
+		}
+
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		c.mutex.RLock()
+		token := c.authToken
+		c.mutex.RUnlock()
+
+		retry, err := c.doGet(ctx, requestURL, token, result)
+		if err == nil {
+			return nil
+		}
+
+		// the token may be revoked before it expires, a fresh one is requested once
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Unauthorized() && !reauthenticated {
+			slog.Warn("Twitch API token rejected, re-authenticating",
+				slog.String("endpoint", endpoint),
+			)
+
+			c.invalidateToken(token)
+			if authErr := c.ensureAuthenticated(ctx); authErr != nil {
+				sentry.CaptureException(authErr)
+				return fmt.Errorf("re-authentication failed: %w", authErr)
+			}
+
+			reauthenticated = true
+			skipBackoff = true
+			// the request with the fresh token does not count as an attempt
+			attempt--
+			continue
+		}
+		skipBackoff = false
+
+		if !retry || ctx.Err() != nil {
+			sentry.CaptureException(err)
+			return err
+		}
+
+		lastErr = err
+
+		slog.Warn("Twitch API request failed, retrying",
+			slog.String("endpoint", endpoint),
+			slog.Int("attempt", attempt+1),
+			slog.Any("error", err),
+		)
+	}
+
+	sentry.CaptureException(lastErr)
+	return fmt.Errorf("giving up after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// waitRetry sleeps the backoff before the next attempt, a 429 has already emptied the limiter
+func (c *Client) waitRetry(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(retryBackoff(attempt - 1))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// doGet sends a single request, returns whether a failure is worth retrying.
+// Responses other than 200 are returned as *APIError
+func (c *Client) doGet(ctx context.Context, requestURL, token string, result any) (bool, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	if err != nil {
-		sentry.CaptureException(err)
-		return nil, fmt.Errorf("creating request failed: %w", err)
+		return false, fmt.Errorf("creating request failed: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.authToken)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Client-Id", c.cfg.Twitch.ClientID)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		sentry.CaptureException(err)
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return true, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	c.limiter.update(resp.Header)
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		err = fmt.Errorf("API request failed: status %d, body: %s", resp.StatusCode, string(body))
-		sentry.CaptureException(err)
-		return nil, err
+		apiErr := newAPIError(resp.StatusCode, body)
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			reset := c.limiter.exhaust(resp.Header)
+			slog.Warn("Twitch API rate limit exceeded",
+				slog.Time("reset", reset),
+			)
+		}
+
+		return apiErr.Retryable, apiErr
 	}
 
-	var clipsResponse ClipsResponse
-	if err = json.NewDecoder(resp.Body).Decode(&clipsResponse); err != nil {
-		sentry.CaptureException(err)
-		return nil, fmt.Errorf("decoding response failed: %w", err)
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return false, fmt.Errorf("decoding response failed: %w", err)
 	}
 
-	return &clipsResponse, nil
+	return false, nil
+}
+
+// Limiter returns the rate limiter shared by every request of the client
+func (c *Client) Limiter() *RateLimiter {
+	return c.limiter
 }
 
 func (c *Client) ensureAuthenticated(ctx context.Context) error {
@@ -118,6 +331,11 @@ func (c *Client) ensureAuthenticated(ctx context.Context) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	// another request may have refreshed the token while this one was waiting for the lock
+	if c.authToken != "" && time.Until(c.tokenExpiry) > tokenRefreshInterval {
+		return nil
+	}
+
 	token, expiry, err := c.getAccessToken(ctx)
 	if err != nil {
 		return err
@@ -128,6 +346,16 @@ func (c *Client) ensureAuthenticated(ctx context.Context) error {
 	return nil
 }
 
+// invalidateToken drops the token unless another request has already replaced it
+func (c *Client) invalidateToken(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.authToken == token {
+		c.authToken = ""
+	}
+}
+
 func (c *Client) getAccessToken(ctx context.Context) (string, time.Time, error) {
 	span := sentry.StartSpan(ctx, "twitch.get_access_token")
 	defer span.Finish()
@@ -137,7 +365,7 @@ func (c *Client) getAccessToken(ctx context.Context) (string, time.Time, error)
 	data.Set("client_secret", c.cfg.Twitch.ClientSecret)
 	data.Set("grant_type", "client_credentials")
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://id.twitch.tv/oauth2/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", authURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		sentry.CaptureException(err)
 		return "", time.Time{}, fmt.Errorf("creating auth request failed: %w", err)
@@ -153,9 +381,9 @@ func (c *Client) getAccessToken(ctx context.Context) (string, time.Time, error)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		err = fmt.Errorf("authentication failed: status %d, body: %s", resp.StatusCode, string(body))
-		sentry.CaptureException(err)
-		return "", time.Time{}, err
+		apiErr := newAPIError(resp.StatusCode, body)
+		sentry.CaptureException(apiErr)
+		return "", time.Time{}, apiErr
 	}
 
 	var authResp authResponse
@@ -0,0 +1,111 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"k0pern1cus/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	oldBaseURL, oldAuthURL := baseURL, authURL
+	baseURL, authURL = server.URL+"/helix", server.URL+"/token"
+	t.Cleanup(func() {
+		baseURL, authURL = oldBaseURL, oldAuthURL
+	})
+
+	return &Client{
+		cfg:        &config.Config{},
+		httpClient: server.Client(),
+		gameNames:  make(map[string]string),
+		limiter:    newRateLimiter(),
+	}
+}
+
+func TestReauthenticateOnUnauthorized(t *testing.T) {
+	var tokens int
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokens++
+			_, _ = w.Write([]byte(`{"access_token":"fresh","expires_in":3600}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer fresh" || tokens < 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"Unauthorized","status":401,"message":"Invalid OAuth token"}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":[{"id":"1","name":"Game"}]}`))
+	})
+
+	res, err := client.GetGames(context.Background(), &GetGamesParams{IDs: []string{"1"}})
+	require.NoError(t, err)
+	require.Equal(t, "Game", res.Data[0].Name)
+	require.Equal(t, 2, tokens)
+}
+
+func TestAPIError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"Bad Request","status":400,"message":"Invalid broadcaster_id"}`))
+	})
+
+	_, err := client.GetClips(context.Background(), &GetClipsParams{BroadcasterID: "x"})
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.Equal(t, "Invalid broadcaster_id", apiErr.Message)
+	require.False(t, apiErr.Retryable)
+}
+
+func TestReauthenticateOnLastAttempt(t *testing.T) {
+	oldMinBackoff := retryMinBackoff
+	retryMinBackoff = time.Millisecond
+	t.Cleanup(func() {
+		retryMinBackoff = oldMinBackoff
+	})
+
+	var tokens, requests int
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokens++
+			_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+			return
+		}
+
+		requests++
+
+		switch {
+		case requests < maxAttempts:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case requests == maxAttempts:
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			_, _ = w.Write([]byte(`{"data":[{"id":"1","name":"Game"}]}`))
+		}
+	})
+
+	res, err := client.GetGames(context.Background(), &GetGamesParams{IDs: []string{"1"}})
+	require.NoError(t, err)
+	require.Equal(t, "Game", res.Data[0].Name)
+	require.Equal(t, maxAttempts+1, requests)
+	require.Equal(t, 2, tokens)
+}
@@ -14,6 +14,24 @@ type GetClipsParams struct {
 	EndedAt       time.Time
 }
 
+// GetGamesParams represents the parameters for getting games
+type GetGamesParams struct {
+	IDs   []string
+	Names []string
+}
+
+// GetUsersParams represents the parameters for getting users
+type GetUsersParams struct {
+	IDs    []string
+	Logins []string
+}
+
+// SearchCategoriesParams represents the parameters for searching categories
+type SearchCategoriesParams struct {
+	Query string
+	First int
+}
+
 // Clip represents a Twitch clip
 type Clip struct {
 	ID              string    `json:"id"`
@@ -41,6 +59,37 @@ type ClipsResponse struct {
 	Pagination *Pagination `json:"pagination,omitempty"`
 }
 
+// Game represents a Twitch game or category
+type Game struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	BoxArtURL string `json:"box_art_url"`
+	IGDBID    string `json:"igdb_id"`
+}
+
+// GamesResponse represents the response from the games endpoint
+type GamesResponse struct {
+	Data []Game `json:"data"`
+}
+
+// User represents a Twitch user
+type User struct {
+	ID          string `json:"id"`
+	Login       string `json:"login"`
+	DisplayName string `json:"display_name"`
+}
+
+// UsersResponse represents the response from the users endpoint
+type UsersResponse struct {
+	Data []User `json:"data"`
+}
+
+// CategoriesResponse represents the response from the search categories endpoint
+type CategoriesResponse struct {
+	Data       []Game      `json:"data"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
 // Pagination represents pagination information
 type Pagination struct {
 	Cursor string `json:"cursor,omitempty"`
@@ -0,0 +1,51 @@
+package twitch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// APIError is a non-200 response of the Twitch API, the error fields are the ones of the response body
+type APIError struct {
+	StatusCode int
+	// ErrorName is the status text, e.g. "Unauthorized"
+	ErrorName string `json:"error"`
+	Message   string `json:"message"`
+	// Retryable is set for rate limiting and server failures, the same request may succeed later
+	Retryable bool
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API request failed: status %d %s", e.StatusCode, e.ErrorName)
+	}
+
+	return fmt.Sprintf("API request failed: status %d %s: %s", e.StatusCode, e.ErrorName, e.Message)
+}
+
+// Unauthorized reports whether the token was rejected
+func (e *APIError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized
+}
+
+// NotFound reports whether the requested resource does not exist
+func (e *APIError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// newAPIError parses the error body, a body that is not JSON ends up in the message
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{}
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		apiErr.Message = string(body)
+	}
+
+	apiErr.StatusCode = statusCode
+	if apiErr.ErrorName == "" {
+		apiErr.ErrorName = http.StatusText(statusCode)
+	}
+	apiErr.Retryable = statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+
+	return apiErr
+}
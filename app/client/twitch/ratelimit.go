@@ -0,0 +1,131 @@
+package twitch
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var maxAttempts = 5
+var retryMinBackoff = time.Second
+var retryMaxBackoff = 30 * time.Second
+
+// fallbackResetInterval is waited on a 429 without a usable Ratelimit-Reset header,
+// Helix refills the bucket continuously and a minute is always enough for a full refill
+var fallbackResetInterval = time.Minute
+
+// RateLimiter tracks the Helix token bucket reported in the Ratelimit-* response headers.
+// Every request to the Helix API goes through the shared limiter of the client
+type RateLimiter struct {
+	m sync.Mutex
+	// remaining is -1 until the first response is seen
+	remaining int
+	reset     time.Time
+}
+
+func newRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		remaining: -1,
+	}
+}
+
+// Wait blocks until a request may be sent without exceeding the bucket and takes a point from it
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait := l.reserve()
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a point from the bucket or returns how long to wait for the refill
+func (l *RateLimiter) reserve() time.Duration {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.remaining != 0 {
+		if l.remaining > 0 {
+			l.remaining--
+		}
+		return 0
+	}
+
+	wait := time.Until(l.reset)
+	if wait <= 0 {
+		// the bucket is refilled, the next response tells by how much
+		l.remaining = -1
+		return 0
+	}
+
+	return wait
+}
+
+// Remaining returns the points left in the bucket and the time it is refilled, remaining is -1 if unknown
+func (l *RateLimiter) Remaining() (int, time.Time) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return l.remaining, l.reset
+}
+
+// update applies the bucket state reported by a response
+func (l *RateLimiter) update(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.remaining = remaining
+	if reset, ok := parseReset(header); ok {
+		l.reset = reset
+	}
+}
+
+// exhaust empties the bucket after a 429 until the reported reset
+func (l *RateLimiter) exhaust(header http.Header) time.Time {
+	reset, ok := parseReset(header)
+	if !ok || !reset.After(time.Now()) {
+		reset = time.Now().Add(fallbackResetInterval)
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.remaining = 0
+	l.reset = reset
+
+	return reset
+}
+
+// parseReset reads the unix timestamp the bucket is refilled at
+func parseReset(header http.Header) (time.Time, bool) {
+	reset, err := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(reset, 0), true
+}
+
+// retryBackoff grows exponentially with the attempt, the jitter keeps parallel workers from retrying in lockstep
+func retryBackoff(attempt int) time.Duration {
+	backoff := min(retryMinBackoff<<min(attempt, 16), retryMaxBackoff)
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+}
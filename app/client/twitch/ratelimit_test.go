@@ -0,0 +1,48 @@
+package twitch
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter()
+
+	// nothing is known before the first response
+	require.Zero(t, limiter.reserve())
+
+	header := http.Header{}
+	header.Set("Ratelimit-Remaining", "1")
+	header.Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	limiter.update(header)
+
+	require.Zero(t, limiter.reserve())
+	require.Greater(t, limiter.reserve(), 59*time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, limiter.Wait(ctx), context.DeadlineExceeded)
+
+	// a 429 with a reset in the past waits the fallback interval
+	header.Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	reset := limiter.exhaust(header)
+	require.WithinDuration(t, time.Now().Add(fallbackResetInterval), reset, time.Second)
+
+	remaining, _ := limiter.Remaining()
+	require.Zero(t, remaining)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for attempt := 0; attempt < 20; attempt++ {
+		backoff := retryBackoff(attempt)
+		base := min(retryMinBackoff<<min(attempt, 16), retryMaxBackoff)
+
+		require.GreaterOrEqual(t, backoff, base/2)
+		require.Less(t, backoff, base*3/2)
+	}
+}
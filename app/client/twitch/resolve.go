@@ -0,0 +1,172 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// usersPerRequest is the Helix limit of logins per Get Users request
+var usersPerRequest = 100
+var categorySuggestions = 5
+
+// ResolveConfig turns the broadcaster logins, the logins of the date range filters and the game name
+// of the config into ids, unknown names are reported all at once
+func (c *Client) ResolveConfig(ctx context.Context) error {
+	twitchCfg := &c.cfg.Twitch
+
+	if len(twitchCfg.Broadcasters) > 0 {
+		ids, err := c.ResolveBroadcasters(ctx, twitchCfg.Broadcasters)
+		if err != nil {
+			return fmt.Errorf("resolve broadcasters: %w", err)
+		}
+
+		for _, id := range ids {
+			if !slices.Contains(twitchCfg.BroadcasterIDs, id) {
+				twitchCfg.BroadcasterIDs = append(twitchCfg.BroadcasterIDs, id)
+			}
+		}
+	}
+
+	if err := c.resolveDateRanges(ctx); err != nil {
+		return fmt.Errorf("resolve broadcaster_dates: %w", err)
+	}
+
+	if twitchCfg.Game != "" {
+		id, err := c.ResolveGame(ctx, twitchCfg.Game)
+		if err != nil {
+			return fmt.Errorf("resolve game: %w", err)
+		}
+
+		twitchCfg.GameID = id
+	}
+
+	return nil
+}
+
+// resolveDateRanges sets the broadcaster id of every date range filter
+func (c *Client) resolveDateRanges(ctx context.Context) error {
+	ranges := c.cfg.Clips.Filters.BroadcasterDates
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	logins := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		logins = append(logins, r.Broadcaster)
+	}
+
+	ids, err := c.ResolveBroadcasters(ctx, logins)
+	if err != nil {
+		return err
+	}
+
+	for i := range ranges {
+		ranges[i].BroadcasterID = ids[i]
+	}
+
+	return nil
+}
+
+// ResolveBroadcasters returns the user ids of the logins in the same order
+func (c *Client) ResolveBroadcasters(ctx context.Context, logins []string) ([]string, error) {
+	byLogin := make(map[string]string, len(logins))
+
+	for batch := range slices.Chunk(logins, usersPerRequest) {
+		normalized := make([]string, 0, len(batch))
+		for _, login := range batch {
+			normalized = append(normalized, strings.ToLower(strings.TrimSpace(login)))
+		}
+
+		res, err := c.GetUsers(ctx, &GetUsersParams{
+			Logins: normalized,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range res.Data {
+			byLogin[user.Login] = user.ID
+		}
+	}
+
+	ids := make([]string, 0, len(logins))
+	var unknown []string
+
+	for _, login := range logins {
+		id, ok := byLogin[strings.ToLower(strings.TrimSpace(login))]
+		if !ok {
+			unknown = append(unknown, login)
+			continue
+		}
+
+		slog.Debug("Resolved broadcaster",
+			slog.String("login", login),
+			slog.String("broadcaster_id", id),
+		)
+		ids = append(ids, id)
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown broadcasters: %s", strings.Join(unknown, ", "))
+	}
+
+	return ids, nil
+}
+
+// ResolveGame returns the id of the game with the exact name, the category search
+// is used to match the name ignoring case and to suggest names if there is no match
+func (c *Client) ResolveGame(ctx context.Context, name string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	res, err := c.GetGames(ctx, &GetGamesParams{
+		Names: []string{name},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(res.Data) > 0 {
+		return c.resolvedGame(name, res.Data[0]), nil
+	}
+
+	search, err := c.SearchCategories(ctx, &SearchCategoriesParams{
+		Query: name,
+		First: categorySuggestions,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	suggestions := make([]string, 0, len(search.Data))
+
+	for _, game := range search.Data {
+		if strings.EqualFold(game.Name, name) {
+			return c.resolvedGame(name, game), nil
+		}
+
+		suggestions = append(suggestions, fmt.Sprintf("%q", game.Name))
+	}
+
+	if len(suggestions) == 0 {
+		return "", fmt.Errorf("unknown game %q", name)
+	}
+
+	return "", fmt.Errorf("unknown game %q, did you mean %s", name, strings.Join(suggestions, ", "))
+}
+
+// resolvedGame caches the game name for the overlays and returns its id
+func (c *Client) resolvedGame(name string, game Game) string {
+	c.mutex.Lock()
+	c.gameNames[game.ID] = game.Name
+	c.mutex.Unlock()
+
+	slog.Debug("Resolved game",
+		slog.String("name", name),
+		slog.String("game_id", game.ID),
+	)
+
+	return game.ID
+}
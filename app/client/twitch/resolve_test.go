@@ -0,0 +1,50 @@
+package twitch
+
+import (
+	"context"
+	"k0pern1cus/pkg/config"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func resolveHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/token":
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+	case "/helix/users":
+		_, _ = w.Write([]byte(`{"data":[{"id":"11","login":"alice"},{"id":"22","login":"bob"}]}`))
+	case "/helix/games":
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	case "/helix/search/categories":
+		_, _ = w.Write([]byte(`{"data":[{"id":"491487","name":"Dead by Daylight"},{"id":"1","name":"Dead Island"}]}`))
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	client := newTestClient(t, resolveHandler)
+	client.cfg.Twitch.BroadcasterIDs = []string{"22"}
+	client.cfg.Twitch.Broadcasters = []string{"Alice", "bob"}
+	client.cfg.Twitch.Game = "dead by daylight"
+	client.cfg.Clips.Filters.BroadcasterDates = []config.BroadcasterDateRange{{Broadcaster: "Bob"}}
+
+	require.NoError(t, client.ResolveConfig(context.Background()))
+	require.Equal(t, []string{"22", "11"}, client.cfg.Twitch.BroadcasterIDs)
+	require.Equal(t, "22", client.cfg.Clips.Filters.BroadcasterDates[0].BroadcasterID)
+	require.Equal(t, "491487", client.cfg.Twitch.GameID)
+
+	name, err := client.GetGameName(context.Background(), "491487")
+	require.NoError(t, err)
+	require.Equal(t, "Dead by Daylight", name)
+}
+
+func TestResolveUnknownNames(t *testing.T) {
+	client := newTestClient(t, resolveHandler)
+
+	_, err := client.ResolveBroadcasters(context.Background(), []string{"alice", "nobody", "ghost"})
+	require.EqualError(t, err, "unknown broadcasters: nobody, ghost")
+
+	_, err = client.ResolveGame(context.Background(), "Dead")
+	require.EqualError(t, err, `unknown game "Dead", did you mean "Dead by Daylight", "Dead Island"`)
+}
@@ -0,0 +1,153 @@
+package clips
+
+import (
+	"context"
+	"fmt"
+	"k0pern1cus/app/client/twitch"
+	"log/slog"
+	"time"
+)
+
+const (
+	ExhaustionStop      = "stop"
+	ExhaustionHistory   = "history"
+	ExhaustionReshuffle = "reshuffle"
+	ExhaustionPlaylist  = "playlist"
+)
+
+var maxPlaylistPageSize = 100
+
+// handleExhaustion is called with the lock held when there is nothing left to pick from the pool
+func (s *Service) handleExhaustion() *ClipHandle {
+	switch s.cfg.Clips.ExhaustionPolicy {
+	case ExhaustionHistory:
+		return s.pickOldestPlayed()
+	case ExhaustionReshuffle:
+		return s.reshuffle()
+	case ExhaustionPlaylist:
+		return s.nextPlaylistClip()
+	default:
+		return nil
+	}
+}
+
+func (s *Service) lastSeen(clipID string) time.Time {
+	lastSeen := s.removedAt[clipID]
+	if playedAt, ok := s.history.LastPlayed(clipID); ok && playedAt.After(lastSeen) {
+		lastSeen = playedAt
+	}
+
+	return lastSeen
+}
+
+// pickOldestPlayed brings back the clip that has not been on air for the longest time
+func (s *Service) pickOldestPlayed() *ClipHandle {
+	var (
+		result     twitch.Clip
+		resultSeen time.Time
+		found      bool
+	)
+
+	for _, clip := range s.catalog {
+		clipSeen := s.lastSeen(clip.ID)
+		if !found || clipSeen.Before(resultSeen) {
+			result = clip
+			resultSeen = clipSeen
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	slog.Debug("Clip pool exhausted, replaying the oldest played clip",
+		slog.String("clip_id", result.ID),
+		slog.Time("last_seen", resultSeen),
+	)
+
+	return s.newHandle(result)
+}
+
+// reshuffle puts the whole catalog back into the pool ignoring the cooldown
+func (s *Service) reshuffle() *ClipHandle {
+	if len(s.catalog) == 0 {
+		return nil
+	}
+
+	for id, clip := range s.catalog {
+		if _, ok := s.clips[id]; !ok {
+			s.clips[id] = s.newHandle(clip)
+		}
+	}
+
+	candidates := make([]*ClipHandle, 0, len(s.clips))
+	for _, clip := range s.clips {
+		candidates = append(candidates, clip)
+	}
+
+	slog.Info("Clip pool exhausted, reshuffling the catalog",
+		slog.Int("count", len(candidates)),
+	)
+
+	return s.selector.Select(candidates)
+}
+
+func (s *Service) nextPlaylistClip() *ClipHandle {
+	if len(s.playlist) == 0 {
+		return nil
+	}
+
+	clip := s.playlist[s.playlistPos%len(s.playlist)]
+	s.playlistPos++
+
+	slog.Debug("Clip pool exhausted, playing fallback playlist",
+		slog.String("clip_id", clip.ID),
+	)
+
+	return s.newHandle(clip)
+}
+
+func (s *Service) loadPlaylist(ctx context.Context) error {
+	ids := s.cfg.Clips.FallbackPlaylist
+	if s.cfg.Clips.ExhaustionPolicy != ExhaustionPlaylist || len(ids) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]twitch.Clip, len(ids))
+
+	for begin := 0; begin < len(ids); begin += maxPlaylistPageSize {
+		end := min(begin+maxPlaylistPageSize, len(ids))
+
+		res, err := s.client.GetClips(ctx, &twitch.GetClipsParams{
+			IDs: ids[begin:end],
+		})
+		if err != nil {
+			return fmt.Errorf("get clips: %w", err)
+		}
+
+		for _, clip := range res.Data {
+			byID[clip.ID] = clip
+		}
+	}
+
+	playlist := make([]twitch.Clip, 0, len(ids))
+	for _, id := range ids {
+		clip, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("clip not found: %s", id)
+		}
+
+		playlist = append(playlist, clip)
+	}
+
+	s.m.Lock()
+	s.playlist = playlist
+	s.m.Unlock()
+
+	slog.Info("Fallback playlist loaded",
+		slog.Int("count", len(playlist)),
+	)
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package clips
+
+import (
+	"context"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/app/service/history"
+	"k0pern1cus/pkg/config"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, cfg *config.Config) *Service {
+	cfg.History.Path = filepath.Join(t.TempDir(), "history.jsonl")
+
+	di := do.New()
+	do.ProvideValue(di, cfg)
+	do.Provide(di, history.New)
+
+	historyService := do.MustInvoke[*history.Service](di)
+	require.NoError(t, historyService.Init(context.Background()))
+	t.Cleanup(func() {
+		_ = historyService.Shutdown()
+	})
+
+	return &Service{
+		cfg:             cfg,
+		history:         historyService,
+		selector:        &randomSelector{},
+		clips:           make(map[string]*ClipHandle),
+		initComplete:    make(chan struct{}),
+		catalog:         make(map[string]twitch.Clip),
+		removedAt:       make(map[string]time.Time),
+		filtered:        make(map[string]string),
+		latestCreatedAt: make(map[string]time.Time),
+	}
+}
+
+func TestReplayedClipFiles(t *testing.T) {
+	cfg := &config.Config{DataDir: "data"}
+	cfg.Clips.ExhaustionPolicy = ExhaustionPlaylist
+	s := newTestService(t, cfg)
+	s.playlist = []twitch.Clip{{ID: "a"}}
+
+	first, ok := s.RemoveClip()
+	require.True(t, ok)
+	second, ok := s.RemoveClip()
+	require.True(t, ok)
+
+	require.Equal(t, first.Clip().ID, second.Clip().ID)
+	require.NotEqual(t, first.getDownloadPath(), second.getDownloadPath())
+	require.NotEqual(t, first.getEncodedPath(""), second.getEncodedPath(""))
+	require.NotEqual(t, first.getEncodedPath("tail"), second.getEncodedPath("tail"))
+}
+
+func TestRejectedClipNotReshuffled(t *testing.T) {
+	cfg := &config.Config{DataDir: "data"}
+	cfg.Clips.ExhaustionPolicy = ExhaustionReshuffle
+	s := newTestService(t, cfg)
+	s.addClips([]twitch.Clip{{ID: "good"}, {ID: "bad"}})
+
+	for range 2 {
+		clip, ok := s.RemoveClip()
+		require.True(t, ok)
+
+		if clip.Clip().ID == "bad" {
+			require.NoError(t, s.Reject(clip, "black"))
+		}
+	}
+
+	for range 10 {
+		clip, ok := s.RemoveClip()
+		require.True(t, ok)
+		require.Equal(t, "good", clip.Clip().ID)
+	}
+
+	_, rejected := s.history.Rejected("bad")
+	require.True(t, rejected)
+}
@@ -0,0 +1,207 @@
+package clips
+
+import (
+	"fmt"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/pkg/clipexpr"
+	"k0pern1cus/pkg/config"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// filterRule is a single eligibility check, allow returns false for the clips the rule rejects
+type filterRule struct {
+	name  string
+	allow func(clip twitch.Clip) bool
+}
+
+// clipFilter is the list of rules a fetched clip has to pass before a handle is created for it
+type clipFilter []filterRule
+
+// Check returns the name of the first rule that rejects the clip or an empty string
+func (f clipFilter) Check(clip twitch.Clip) string {
+	for _, rule := range f {
+		if !rule.allow(clip) {
+			return rule.name
+		}
+	}
+
+	return ""
+}
+
+// newClipFilter builds the rules out of the config, only the configured ones are checked
+func newClipFilter(cfg *config.Config) (clipFilter, error) {
+	filters := &cfg.Clips.Filters
+
+	var result clipFilter
+
+	// the game name is resolved into GameID after the filter is built
+	result = append(result, filterRule{"game", func(clip twitch.Clip) bool {
+		return clip.GameID == cfg.Twitch.GameID || slices.Contains(cfg.Twitch.GameIDs, clip.GameID)
+	}})
+
+	if len(filters.Languages) > 0 {
+		result = append(result, filterRule{"language", func(clip twitch.Clip) bool {
+			return containsFold(filters.Languages, clip.Language)
+		}})
+	}
+
+	if filters.MinDuration > 0 {
+		result = append(result, filterRule{"min_duration", func(clip twitch.Clip) bool {
+			return clip.Duration >= filters.MinDuration
+		}})
+	}
+
+	if filters.MaxDuration > 0 {
+		result = append(result, filterRule{"max_duration", func(clip twitch.Clip) bool {
+			return clip.Duration <= filters.MaxDuration
+		}})
+	}
+
+	if filters.MinViews > 0 {
+		result = append(result, filterRule{"min_views", func(clip twitch.Clip) bool {
+			return clip.ViewCount >= filters.MinViews
+		}})
+	}
+
+	if len(filters.BlockedCreators) > 0 {
+		result = append(result, filterRule{"blocked_creators", func(clip twitch.Clip) bool {
+			return !containsFold(filters.BlockedCreators, clip.CreatorID) && !containsFold(filters.BlockedCreators, clip.CreatorName)
+		}})
+	}
+
+	if len(filters.BroadcasterDates) > 0 {
+		result = append(result, filterRule{"broadcaster_dates", func(clip twitch.Clip) bool {
+			return inBroadcasterDates(filters.BroadcasterDates, clip)
+		}})
+	}
+
+	if len(filters.TitleInclude) > 0 {
+		include, err := compilePatterns(filters.TitleInclude)
+		if err != nil {
+			return nil, fmt.Errorf("title_include: %w", err)
+		}
+
+		result = append(result, filterRule{"title_include", func(clip twitch.Clip) bool {
+			return slices.ContainsFunc(include, func(re *regexp.Regexp) bool {
+				return re.MatchString(clip.Title)
+			})
+		}})
+	}
+
+	if len(filters.TitleExclude) > 0 {
+		exclude, err := compilePatterns(filters.TitleExclude)
+		if err != nil {
+			return nil, fmt.Errorf("title_exclude: %w", err)
+		}
+
+		result = append(result, filterRule{"title_exclude", func(clip twitch.Clip) bool {
+			return !slices.ContainsFunc(exclude, func(re *regexp.Regexp) bool {
+				return re.MatchString(clip.Title)
+			})
+		}})
+	}
+
+	if filters.FeaturedOnly {
+		result = append(result, filterRule{"featured_only", func(clip twitch.Clip) bool {
+			return clip.IsFeatured
+		}})
+	}
+
+	if filters.Expression != "" {
+		program, err := clipexpr.CompileFilter(filters.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("expression: %w", err)
+		}
+
+		result = append(result, filterRule{"expression", func(clip twitch.Clip) bool {
+			ok, err := clipexpr.Filter(program, exprEnv(clip))
+			if err != nil {
+				slog.Warn("Clip filter expression failed",
+					slog.String("clip_id", clip.ID),
+					slog.Any("error", err),
+				)
+				return false
+			}
+
+			return ok
+		}})
+	}
+
+	return result, nil
+}
+
+// inBroadcasterDates checks the clip against the ranges of its broadcaster, other broadcasters are not limited
+func inBroadcasterDates(ranges []config.BroadcasterDateRange, clip twitch.Clip) bool {
+	for _, r := range ranges {
+		if r.BroadcasterID != clip.BroadcasterID {
+			continue
+		}
+
+		if !r.From.IsZero() && clip.CreatedAt.Before(r.From) {
+			return false
+		}
+
+		if !r.To.IsZero() && !clip.CreatedAt.Before(rangeEnd(r.To)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rangeEnd returns the first moment after the range, YAML decodes a date into its midnight,
+// so a date-only end covers the whole day
+func rangeEnd(to time.Time) time.Time {
+	if hour, minute, second := to.Clock(); hour == 0 && minute == 0 && second == 0 && to.Nanosecond() == 0 {
+		return to.AddDate(0, 0, 1)
+	}
+
+	return to.Add(time.Nanosecond)
+}
+
+// exprEnv exposes the clip to the filter and score expressions
+func exprEnv(clip twitch.Clip) clipexpr.Env {
+	return clipexpr.Env{
+		ID:              clip.ID,
+		URL:             clip.URL,
+		BroadcasterID:   clip.BroadcasterID,
+		BroadcasterName: clip.BroadcasterName,
+		CreatorID:       clip.CreatorID,
+		CreatorName:     clip.CreatorName,
+		VideoID:         clip.VideoID,
+		GameID:          clip.GameID,
+		Language:        clip.Language,
+		Title:           clip.Title,
+		ViewCount:       clip.ViewCount,
+		CreatedAt:       clip.CreatedAt,
+		Duration:        clip.Duration,
+		VodOffset:       clip.VodOffset,
+		IsFeatured:      clip.IsFeatured,
+		AgeHours:        time.Since(clip.CreatedAt).Hours(),
+	}
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile %q: %w", pattern, err)
+		}
+
+		result = append(result, re)
+	}
+
+	return result, nil
+}
+
+func containsFold(list []string, value string) bool {
+	return slices.ContainsFunc(list, func(item string) bool {
+		return strings.EqualFold(item, value)
+	})
+}
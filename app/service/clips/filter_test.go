@@ -0,0 +1,82 @@
+package clips
+
+import (
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/pkg/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClipFilter(t *testing.T) {
+	var cfg config.Config
+	cfg.Twitch.GameID = "1"
+	cfg.Twitch.GameIDs = []string{"2"}
+	cfg.Clips.Filters = config.ClipFilters{
+		Languages:       []string{"ru", "EN"},
+		MinDuration:     10,
+		MaxDuration:     50,
+		MinViews:        100,
+		BlockedCreators: []string{"spammer"},
+		BroadcasterDates: []config.BroadcasterDateRange{{
+			Broadcaster:   "alice",
+			BroadcasterID: "11",
+			From:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:            time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		}},
+		TitleInclude: []string{`(?i)clutch`, `(?i)win`},
+		TitleExclude: []string{`(?i)\bbug\b`},
+		Expression:   `view_count > 200 || is_featured`,
+	}
+
+	filter, err := newClipFilter(&cfg)
+	require.NoError(t, err)
+
+	clip := twitch.Clip{
+		GameID:          "2",
+		Language:        "en",
+		Duration:        30,
+		ViewCount:       500,
+		CreatorName:     "bob",
+		BroadcasterID:   "11",
+		BroadcasterName: "alice",
+		CreatedAt:       time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Title:           "Clutch 1v4",
+	}
+	require.Empty(t, filter.Check(clip))
+
+	cases := map[string]func(clip *twitch.Clip){
+		"game":              func(clip *twitch.Clip) { clip.GameID = "3" },
+		"language":          func(clip *twitch.Clip) { clip.Language = "de" },
+		"min_duration":      func(clip *twitch.Clip) { clip.Duration = 5 },
+		"max_duration":      func(clip *twitch.Clip) { clip.Duration = 60 },
+		"min_views":         func(clip *twitch.Clip) { clip.ViewCount = 10 },
+		"blocked_creators":  func(clip *twitch.Clip) { clip.CreatorName = "Spammer" },
+		"broadcaster_dates": func(clip *twitch.Clip) { clip.CreatedAt = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC) },
+		"title_include":     func(clip *twitch.Clip) { clip.Title = "funny moment" },
+		"title_exclude":     func(clip *twitch.Clip) { clip.Title = "clutch with a bug" },
+		"expression":        func(clip *twitch.Clip) { clip.ViewCount = 150 },
+	}
+
+	for rule, mutate := range cases {
+		rejected := clip
+		mutate(&rejected)
+		require.Equal(t, rule, filter.Check(rejected))
+	}
+
+	// a date-only end covers the whole day
+	clip.CreatedAt = time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC)
+	require.Empty(t, filter.Check(clip))
+	clip.CreatedAt = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, "broadcaster_dates", filter.Check(clip))
+
+	// other broadcasters are not limited by the date range, the display name is not matched
+	clip.BroadcasterID = "22"
+	clip.CreatedAt = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Empty(t, filter.Check(clip))
+
+	cfg.Clips.Filters.TitleExclude = []string{"("}
+	_, err = newClipFilter(&cfg)
+	require.Error(t, err)
+}
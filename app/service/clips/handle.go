@@ -2,15 +2,14 @@ package clips
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"k0pern1cus/app/client/clip_downloader"
 	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/app/service/encoder"
+	"k0pern1cus/pkg/config"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
 	"sync/atomic"
 	"time"
 
@@ -25,8 +24,19 @@ type ClipHandle struct {
 	prepared        atomic.Bool
 	preciseDuration atomic.Pointer[time.Duration]
 
+	cfg        *config.Config
 	clip       twitch.Clip
 	downloader *clip_downloader.Downloader
+	encoder    *encoder.Service
+	segments   encoder.ClipSegments
+
+	// name is unique per handle, so handles of the same clip never share files
+	name string
+
+	// set before readyChan is closed
+	rejectReason  string
+	trimmed       *encoder.Interval
+	titleDuration time.Duration
 
 	readyChan chan struct{}
 }
@@ -76,7 +86,7 @@ func (h *ClipHandle) download(ctx context.Context, localHub *sentry.Hub) error {
 	return fmt.Errorf("unexpected error")
 }
 
-func (h *ClipHandle) measurePreciseDuration(ctx context.Context, localHub *sentry.Hub) (time.Duration, error) {
+func (h *ClipHandle) measurePreciseDuration(ctx context.Context, localHub *sentry.Hub, path string) (time.Duration, error) {
 	span := sentry.StartSpan(ctx, "clip_handle.measure_duration")
 	defer span.Finish()
 
@@ -84,48 +94,72 @@ func (h *ClipHandle) measurePreciseDuration(ctx context.Context, localHub *sentr
 
 	beginTime := time.Now()
 
-	args := []string{
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		h.getDownloadPath(),
-	}
-
-	cmd := exec.CommandContext(ctx, "ffprobe", args...)
-
-	output, err := cmd.Output()
+	duration, err := h.encoder.ProbeDuration(ctx, path)
 	if err != nil {
 		localHub.CaptureException(err)
-		return 0, fmt.Errorf("ffprobe failed: %w", err)
+		return 0, fmt.Errorf("probe duration: %w", err)
 	}
 
-	var probeOutput struct {
-		Format struct {
-			Duration string `json:"duration"`
-		} `json:"format"`
-	}
-	if err = json.Unmarshal(output, &probeOutput); err != nil {
-		localHub.CaptureException(err)
-		return 0, fmt.Errorf("parse ffprobe output: %w", err)
-	}
+	slog.Debug("Clip duration measurement finished",
+		slog.String("clip_id", h.clip.ID),
+		slog.Duration("exec_time", time.Since(beginTime)),
+	)
 
-	if probeOutput.Format.Duration == "" {
-		localHub.CaptureException(err)
-		return 0, fmt.Errorf("no duration found in ffprobe output")
+	return duration, nil
+}
+
+// measureLoudness returns nil if normalization is disabled or the measurement failed,
+// the clip is aired at its original loudness then
+func (h *ClipHandle) measureLoudness(ctx context.Context, source encoder.ClipSource) *encoder.LoudnessMeasurement {
+	if !h.cfg.Audio.Loudnorm.Enabled {
+		return nil
 	}
 
-	durationSec, err := strconv.ParseFloat(probeOutput.Format.Duration, 64)
+	beginTime := time.Now()
+
+	loudness, err := h.encoder.MeasureLoudness(ctx, source)
 	if err != nil {
-		localHub.CaptureException(err)
-		return 0, fmt.Errorf("parse duration: %w", err)
+		slog.Warn("Measure clip loudness failed",
+			slog.String("clip_id", h.clip.ID),
+			slog.Any("error", err),
+		)
+		return nil
 	}
 
-	slog.Debug("Clip duration measurement finished",
+	slog.Debug("Clip loudness measurement finished",
 		slog.String("clip_id", h.clip.ID),
+		slog.String("integrated", loudness.InputI),
 		slog.Duration("exec_time", time.Since(beginTime)),
 	)
 
-	return time.Duration(durationSec * float64(time.Second)), nil
+	return loudness
+}
+
+// encodeTitleCard returns an empty path if the card could not be made, the clip airs without it then
+func (h *ClipHandle) encodeTitleCard(ctx context.Context, localHub *sentry.Hub) string {
+	output := h.getEncodedPath("title")
+
+	if err := h.encoder.EncodeTitleCard(ctx, h.clip, output); err != nil {
+		localHub.CaptureException(err)
+		slog.Warn("Title card encoding failed",
+			slog.String("clip_id", h.clip.ID),
+			slog.Any("error", err),
+		)
+		return ""
+	}
+
+	duration, err := h.measurePreciseDuration(ctx, localHub, output)
+	if err != nil {
+		slog.Warn("Measure title card duration failed",
+			slog.String("clip_id", h.clip.ID),
+			slog.Any("error", err),
+		)
+		return ""
+	}
+
+	h.titleDuration = duration
+
+	return output
 }
 
 func (h *ClipHandle) prepareAsync(ctx context.Context) {
@@ -141,7 +175,60 @@ func (h *ClipHandle) prepareAsync(ctx context.Context) {
 		return
 	}
 
-	duration, err := h.measurePreciseDuration(ctx, localHub)
+	// the source is not needed once the ready to air segments are produced
+	defer os.Remove(h.getDownloadPath())
+
+	sourceDuration, err := h.measurePreciseDuration(ctx, localHub, h.getDownloadPath())
+	if err != nil {
+		slog.Error("Measure source duration for clip failed",
+			slog.String("clip_id", h.clip.ID),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	source := encoder.ClipSource{
+		Path:     h.getDownloadPath(),
+		Duration: sourceDuration,
+	}
+
+	if h.cfg.Quality.Enabled || h.cfg.Trim.Enabled {
+		analysis, reason, err := h.inspect(ctx, localHub, sourceDuration)
+		if err != nil {
+			slog.Error("Clip inspection failed",
+				slog.String("clip_id", h.clip.ID),
+				slog.Any("error", err),
+			)
+			return
+		}
+
+		if reason != "" {
+			h.rejectReason = reason
+			return
+		}
+
+		if h.cfg.Trim.Enabled {
+			h.trim(&source, analysis)
+		}
+	}
+
+	source.Loudness = h.measureLoudness(ctx, source)
+
+	segments, err := h.encoder.EncodeClip(ctx, h.clip, source, encoder.ClipSegments{
+		Body: h.getEncodedPath(""),
+		Head: h.getEncodedPath("head"),
+		Tail: h.getEncodedPath("tail"),
+	})
+	if err != nil {
+		localHub.CaptureException(err)
+		slog.Error("Clip encoding failed",
+			slog.String("clip_id", h.clip.ID),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	duration, err := h.measurePreciseDuration(ctx, localHub, segments.Body)
 	if err != nil {
 		slog.Error("Measure precise duration for clip failed",
 			slog.String("clip_id", h.clip.ID),
@@ -150,18 +237,65 @@ func (h *ClipHandle) prepareAsync(ctx context.Context) {
 		return
 	}
 
+	if h.cfg.TitleCard.Enabled {
+		segments.Title = h.encodeTitleCard(ctx, localHub)
+	}
+
+	h.segments = segments
 	h.preciseDuration.Store(&duration)
 	h.prepared.Store(true)
 }
 
 func (h *ClipHandle) getDownloadPath() string {
-	return filepath.Join("data", h.clip.ID+".mp4")
+	return filepath.Join(h.cfg.DataDir, h.name+".mp4")
+}
+
+func (h *ClipHandle) getEncodedPath(suffix string) string {
+	if suffix != "" {
+		return filepath.Join(h.cfg.DataDir, h.name+"_"+suffix+".ts")
+	}
+
+	return filepath.Join(h.cfg.DataDir, h.name+".ts")
+}
+
+// GetPreparedFile returns the encoded mpegts segment and whether the preparation succeeded
+func (h *ClipHandle) GetPreparedFile() (string, bool) {
+	return h.getEncodedPath(""), h.prepared.Load()
+}
+
+// GetCrossfadeFiles returns the head and tail segments, they are absent for clips too short to crossfade
+func (h *ClipHandle) GetCrossfadeFiles() (string, string, bool) {
+	if !h.prepared.Load() || h.segments.Head == "" {
+		return "", "", false
+	}
+
+	return h.segments.Head, h.segments.Tail, true
 }
 
-func (h *ClipHandle) GetDownloadedFile() (string, bool) {
-	return h.getDownloadPath(), h.prepared.Load()
+// Rejected returns the reason the clip did not pass the quality gate, valid once the preparation is done
+func (h *ClipHandle) Rejected() (string, bool) {
+	return h.rejectReason, h.rejectReason != ""
+}
+
+// Trimmed returns the part of the source that went into the encode, valid once the preparation is done
+func (h *ClipHandle) Trimmed() (encoder.Interval, bool) {
+	if h.trimmed == nil {
+		return encoder.Interval{}, false
+	}
+
+	return *h.trimmed, true
+}
+
+// GetTitleCardFile returns the title card segment and its duration, it is absent if the card is disabled or failed
+func (h *ClipHandle) GetTitleCardFile() (string, time.Duration, bool) {
+	if !h.prepared.Load() || h.segments.Title == "" {
+		return "", 0, false
+	}
+
+	return h.segments.Title, h.titleDuration, true
 }
 
+// GetPreciseDuration is measured on the encoded segment, so it accounts for the trimmed dead air
 func (h *ClipHandle) GetPreciseDuration() time.Duration {
 	duration := h.preciseDuration.Load()
 	if duration == nil {
@@ -177,4 +311,8 @@ func (h *ClipHandle) Clip() twitch.Clip {
 
 func (h *ClipHandle) Release() {
 	_ = os.Remove(h.getDownloadPath())
+	_ = os.Remove(h.getEncodedPath(""))
+	_ = os.Remove(h.getEncodedPath("head"))
+	_ = os.Remove(h.getEncodedPath("tail"))
+	_ = os.Remove(h.getEncodedPath("title"))
 }
@@ -0,0 +1,83 @@
+package clips
+
+import (
+	"context"
+	"fmt"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/app/service/encoder"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// ProbeReport is what the quality gate and the dead air trimming see in a clip
+type ProbeReport struct {
+	Probe    *encoder.ProbeResult
+	Analysis *encoder.Analysis
+	// Trim is the part that would be aired, nil if nothing would be cut
+	Trim *encoder.Interval
+	// RejectReason is set if the clip would not pass the quality gate
+	RejectReason string
+}
+
+// Probe inspects a local file or downloads a clip by its slug or URL and inspects it,
+// the quality gate and trimming thresholds are applied even if they are disabled
+func (s *Service) Probe(ctx context.Context, clip string) (*ProbeReport, error) {
+	span := sentry.StartSpan(ctx, "clips.probe")
+	defer span.Finish()
+
+	handle := s.newHandle(twitch.Clip{ID: clipSlug(clip)})
+
+	input := clip
+	if _, err := os.Stat(clip); err != nil {
+		if err = handle.download(ctx, sentry.CurrentHub()); err != nil {
+			return nil, fmt.Errorf("download: %w", err)
+		}
+		defer os.Remove(handle.getDownloadPath())
+
+		input = handle.getDownloadPath()
+	}
+
+	probe, err := s.encoder.Probe(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("probe: %w", err)
+	}
+
+	duration, err := probe.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("duration: %w", err)
+	}
+
+	analysis, err := s.encoder.Analyze(ctx, input, duration, probe.HasAudio())
+	if err != nil {
+		return nil, fmt.Errorf("analyze: %w", err)
+	}
+
+	report := &ProbeReport{
+		Probe:        probe,
+		Analysis:     analysis,
+		RejectReason: handle.checkStreams(probe),
+	}
+
+	if report.RejectReason == "" {
+		report.RejectReason = handle.checkAnalysis(analysis)
+	}
+
+	if interval, ok := handle.trimPoints(analysis); ok {
+		report.Trim = &interval
+	}
+
+	return report, nil
+}
+
+// clipSlug accepts both the clip slug and the clip URL, e.g. https://clips.twitch.tv/<slug>
+func clipSlug(clip string) string {
+	clip = strings.TrimSuffix(clip, "/")
+	if !strings.Contains(clip, "/") {
+		return clip
+	}
+
+	return path.Base(strings.SplitN(clip, "?", 2)[0])
+}
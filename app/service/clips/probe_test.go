@@ -0,0 +1,14 @@
+package clips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClipSlug(t *testing.T) {
+	require.Equal(t, "QuaintAssiduousZebra", clipSlug("QuaintAssiduousZebra"))
+	require.Equal(t, "QuaintAssiduousZebra", clipSlug("https://clips.twitch.tv/QuaintAssiduousZebra"))
+	require.Equal(t, "QuaintAssiduousZebra", clipSlug("https://www.twitch.tv/streamer/clip/QuaintAssiduousZebra?filter=clips"))
+	require.Equal(t, "QuaintAssiduousZebra", clipSlug("https://clips.twitch.tv/QuaintAssiduousZebra/"))
+}
@@ -0,0 +1,96 @@
+package clips
+
+import (
+	"context"
+	"fmt"
+	"k0pern1cus/app/service/encoder"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// inspect probes and scans the downloaded source, the quality gate is applied if enabled,
+// returns the analysis and the rejection reason or an empty string
+func (h *ClipHandle) inspect(ctx context.Context, localHub *sentry.Hub, sourceDuration time.Duration) (*encoder.Analysis, string, error) {
+	span := sentry.StartSpan(ctx, "clip_handle.inspect")
+	defer span.Finish()
+
+	span.SetTag("clip_id", h.clip.ID)
+
+	beginTime := time.Now()
+
+	probe, err := h.encoder.Probe(ctx, h.getDownloadPath())
+	if err != nil {
+		localHub.CaptureException(err)
+		return nil, "", fmt.Errorf("probe: %w", err)
+	}
+
+	if h.cfg.Quality.Enabled {
+		if reason := h.checkStreams(probe); reason != "" {
+			return nil, reason, nil
+		}
+	}
+
+	analysis, err := h.encoder.Analyze(ctx, h.getDownloadPath(), sourceDuration, probe.HasAudio())
+	if err != nil {
+		localHub.CaptureException(err)
+		return nil, "", fmt.Errorf("analyze: %w", err)
+	}
+
+	slog.Debug("Clip inspection finished",
+		slog.String("clip_id", h.clip.ID),
+		slog.Float64("black_ratio", analysis.BlackRatio()),
+		slog.Float64("frozen_ratio", analysis.FrozenRatio()),
+		slog.Float64("silent_ratio", analysis.SilentRatio()),
+		slog.Duration("exec_time", time.Since(beginTime)),
+	)
+
+	if !h.cfg.Quality.Enabled {
+		return analysis, "", nil
+	}
+
+	return analysis, h.checkAnalysis(analysis), nil
+}
+
+func (h *ClipHandle) checkStreams(probe *encoder.ProbeResult) string {
+	quality := &h.cfg.Quality
+
+	video := probe.VideoStream()
+	if video == nil {
+		return "no video stream"
+	}
+
+	// the clip encode fills a missing audio stream with silence, rejecting such clips is up to the config
+	if quality.RequireAudio && !probe.HasAudio() {
+		return "no audio stream"
+	}
+
+	if video.Height < quality.MinHeight {
+		return fmt.Sprintf("resolution %dx%d is below %dp", video.Width, video.Height, quality.MinHeight)
+	}
+
+	if fps := video.FrameRate(); fps < quality.MinFPS {
+		return fmt.Sprintf("frame rate %.2f is below %.2f", fps, quality.MinFPS)
+	}
+
+	return ""
+}
+
+func (h *ClipHandle) checkAnalysis(analysis *encoder.Analysis) string {
+	quality := &h.cfg.Quality
+
+	if ratio := analysis.BlackRatio(); ratio > quality.MaxBlackRatio {
+		return fmt.Sprintf("%.0f%% of the clip is black", ratio*100)
+	}
+
+	if ratio := analysis.FrozenRatio(); ratio > quality.MaxFrozenRatio {
+		return fmt.Sprintf("%.0f%% of the clip is frozen", ratio*100)
+	}
+
+	if ratio := analysis.SilentRatio(); ratio > quality.MaxSilentRatio {
+		return fmt.Sprintf("%.0f%% of the clip is silent", ratio*100)
+	}
+
+	return ""
+}
@@ -0,0 +1,27 @@
+package clips
+
+import (
+	"k0pern1cus/app/service/encoder"
+	"k0pern1cus/pkg/config"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStreamsAudio(t *testing.T) {
+	var cfg config.Config
+	handle := &ClipHandle{cfg: &cfg}
+
+	silent := &encoder.ProbeResult{
+		Streams: []encoder.ProbeStream{{CodecType: "video", Width: 1920, Height: 1080, AvgFrameRate: "60/1"}},
+	}
+
+	// a clip without audio airs with a silent track
+	require.Empty(t, handle.checkStreams(silent))
+
+	cfg.Quality.RequireAudio = true
+	require.Equal(t, "no audio stream", handle.checkStreams(silent))
+
+	silent.Streams = append(silent.Streams, encoder.ProbeStream{CodecType: "audio"})
+	require.Empty(t, handle.checkStreams(silent))
+}
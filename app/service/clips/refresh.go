@@ -0,0 +1,62 @@
+package clips
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// refreshLoop periodically picks up clips created after the initial fetch, a negative interval turns it off
+func (s *Service) refreshLoop(ctx context.Context) {
+	if s.cfg.Clips.RefreshInterval < 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.Clips.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+func (s *Service) refresh(ctx context.Context) {
+	localHub := sentry.CurrentHub().Clone()
+
+	span := sentry.StartSpan(ctx, "clips.refresh")
+	defer span.Finish()
+	defer sentry.Recover()
+
+	beginTime := time.Now()
+	countBefore, _ := s.Stats()
+
+	for _, broadcasterID := range s.cfg.Twitch.BroadcasterIDs {
+		s.m.RLock()
+		startedAt, ok := s.latestCreatedAt[broadcasterID]
+		s.m.RUnlock()
+
+		if !ok {
+			startedAt = beginTime.Add(-s.cfg.Clips.RefreshInterval)
+		}
+
+		if !s.fetchWindow(ctx, localHub, broadcasterID, startedAt, beginTime, 0) {
+			return
+		}
+	}
+
+	countAfter, duration := s.Stats()
+
+	slog.Info("Clips refreshed",
+		slog.Int("new_count", countAfter-countBefore),
+		slog.Int("count", countAfter),
+		slog.Float64("duration", duration),
+		slog.Duration("exec_time", time.Since(beginTime)),
+	)
+}
@@ -0,0 +1,211 @@
+package clips
+
+import (
+	"fmt"
+	"k0pern1cus/pkg/clipexpr"
+	"log/slog"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SelectorRandom        = "random"
+	SelectorViews         = "views"
+	SelectorVelocity      = "velocity"
+	SelectorRoundRobin    = "round_robin"
+	SelectorNoRepeat      = "no_repeat"
+	SelectorChronological = "chronological"
+	// SelectorScore is created with NewScoreSelector, the weight comes from the score expression
+	SelectorScore = "score"
+)
+
+var minVelocityAge = time.Hour
+
+// Selector picks the next clip to be played out of the available candidates
+type Selector interface {
+	Select(candidates []*ClipHandle) *ClipHandle
+}
+
+func NewSelector(name string) (Selector, error) {
+	switch name {
+	case "", SelectorRandom:
+		return &randomSelector{}, nil
+	case SelectorViews:
+		return &weightedSelector{weight: viewsWeight}, nil
+	case SelectorVelocity:
+		return &weightedSelector{weight: velocityWeight}, nil
+	case SelectorRoundRobin:
+		return &roundRobinSelector{}, nil
+	case SelectorNoRepeat:
+		return &noRepeatSelector{}, nil
+	case SelectorChronological:
+		return &chronologicalSelector{}, nil
+	default:
+		return nil, fmt.Errorf("unknown selector: %s", name)
+	}
+}
+
+// NewScoreSelector picks clips at random weighted by the score expression, clips scored zero or below,
+// NaN or infinite are only picked if no clip has a positive score
+func NewScoreSelector(score string) (Selector, error) {
+	program, err := clipexpr.CompileScore(score)
+	if err != nil {
+		return nil, err
+	}
+
+	return &weightedSelector{weight: func(handle *ClipHandle) float64 {
+		weight, err := clipexpr.Score(program, exprEnv(handle.clip))
+		if err != nil {
+			slog.Debug("Clip score expression failed",
+				slog.String("clip_id", handle.clip.ID),
+				slog.Any("error", err),
+			)
+			return 0
+		}
+
+		// a single NaN or infinite weight would make the total useless and pin the rotation to one clip
+		if math.IsNaN(weight) || math.IsInf(weight, 0) {
+			slog.Debug("Clip score is not finite",
+				slog.String("clip_id", handle.clip.ID),
+				slog.Float64("score", weight),
+			)
+			return 0
+		}
+
+		return weight
+	}}, nil
+}
+
+type randomSelector struct{}
+
+func (s *randomSelector) Select(candidates []*ClipHandle) *ClipHandle {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	return candidates[rand.Intn(len(candidates))]
+}
+
+type weightedSelector struct {
+	weight func(handle *ClipHandle) float64
+}
+
+func (s *weightedSelector) Select(candidates []*ClipHandle) *ClipHandle {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	weights := make([]float64, len(candidates))
+	total := 0.0
+
+	for i, candidate := range candidates {
+		weights[i] = max(s.weight(candidate), 0)
+		total += weights[i]
+	}
+
+	if total <= 0 {
+		return candidates[rand.Intn(len(candidates))]
+	}
+
+	target := rand.Float64() * total
+	for i, weight := range weights {
+		target -= weight
+		if target < 0 {
+			return candidates[i]
+		}
+	}
+
+	return candidates[len(candidates)-1]
+}
+
+func viewsWeight(handle *ClipHandle) float64 {
+	// every clip keeps a small chance to be picked
+	return float64(handle.clip.ViewCount + 1)
+}
+
+func velocityWeight(handle *ClipHandle) float64 {
+	age := max(time.Since(handle.clip.CreatedAt), minVelocityAge)
+	return float64(handle.clip.ViewCount+1) / age.Hours()
+}
+
+// roundRobinSelector cycles through broadcasters so that none of them dominates the stream
+type roundRobinSelector struct {
+	lastBroadcasterID string
+}
+
+func (s *roundRobinSelector) Select(candidates []*ClipHandle) *ClipHandle {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	byBroadcaster := make(map[string][]*ClipHandle)
+	for _, candidate := range candidates {
+		byBroadcaster[candidate.clip.BroadcasterID] = append(byBroadcaster[candidate.clip.BroadcasterID], candidate)
+	}
+
+	broadcasterIDs := make([]string, 0, len(byBroadcaster))
+	for broadcasterID := range byBroadcaster {
+		broadcasterIDs = append(broadcasterIDs, broadcasterID)
+	}
+	slices.Sort(broadcasterIDs)
+
+	next := broadcasterIDs[0]
+	for _, broadcasterID := range broadcasterIDs {
+		if broadcasterID > s.lastBroadcasterID {
+			next = broadcasterID
+			break
+		}
+	}
+
+	s.lastBroadcasterID = next
+
+	broadcasterClips := byBroadcaster[next]
+	return broadcasterClips[rand.Intn(len(broadcasterClips))]
+}
+
+// noRepeatSelector never picks the same broadcaster twice in a row unless there is no other choice
+type noRepeatSelector struct {
+	lastBroadcasterID string
+}
+
+func (s *noRepeatSelector) Select(candidates []*ClipHandle) *ClipHandle {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	filtered := make([]*ClipHandle, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.clip.BroadcasterID != s.lastBroadcasterID {
+			filtered = append(filtered, candidate)
+		}
+	}
+
+	if len(filtered) == 0 {
+		filtered = candidates
+	}
+
+	result := filtered[rand.Intn(len(filtered))]
+	s.lastBroadcasterID = result.clip.BroadcasterID
+
+	return result
+}
+
+// chronologicalSelector plays clips in the order they were created
+type chronologicalSelector struct{}
+
+func (s *chronologicalSelector) Select(candidates []*ClipHandle) *ClipHandle {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	return slices.MinFunc(candidates, func(a, b *ClipHandle) int {
+		if c := a.clip.CreatedAt.Compare(b.clip.CreatedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.clip.ID, b.clip.ID)
+	})
+}
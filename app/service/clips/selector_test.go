@@ -0,0 +1,118 @@
+package clips
+
+import (
+	"k0pern1cus/app/client/twitch"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandle(id, broadcasterID string, createdAt time.Time) *ClipHandle {
+	return &ClipHandle{
+		clip: twitch.Clip{
+			ID:            id,
+			BroadcasterID: broadcasterID,
+			CreatedAt:     createdAt,
+		},
+	}
+}
+
+func TestRoundRobinSelector(t *testing.T) {
+	now := time.Now()
+	candidates := []*ClipHandle{
+		newTestHandle("a1", "a", now),
+		newTestHandle("a2", "a", now),
+		newTestHandle("b1", "b", now),
+		newTestHandle("c1", "c", now),
+	}
+
+	selector, err := NewSelector(SelectorRoundRobin)
+	require.NoError(t, err)
+
+	var broadcasters []string
+	for range 4 {
+		broadcasters = append(broadcasters, selector.Select(candidates).clip.BroadcasterID)
+	}
+
+	require.Equal(t, []string{"a", "b", "c", "a"}, broadcasters)
+}
+
+func TestNoRepeatSelector(t *testing.T) {
+	now := time.Now()
+	candidates := []*ClipHandle{
+		newTestHandle("a1", "a", now),
+		newTestHandle("a2", "a", now),
+		newTestHandle("b1", "b", now),
+	}
+
+	selector, err := NewSelector(SelectorNoRepeat)
+	require.NoError(t, err)
+
+	last := selector.Select(candidates).clip.BroadcasterID
+	for range 10 {
+		current := selector.Select(candidates).clip.BroadcasterID
+		require.NotEqual(t, last, current)
+		last = current
+	}
+}
+
+func TestChronologicalSelector(t *testing.T) {
+	now := time.Now()
+	candidates := []*ClipHandle{
+		newTestHandle("new", "a", now),
+		newTestHandle("old", "b", now.Add(-time.Hour)),
+		newTestHandle("mid", "c", now.Add(-time.Minute)),
+	}
+
+	selector, err := NewSelector(SelectorChronological)
+	require.NoError(t, err)
+
+	require.Equal(t, "old", selector.Select(candidates).clip.ID)
+}
+
+func TestUnknownSelector(t *testing.T) {
+	_, err := NewSelector("unknown")
+	require.Error(t, err)
+}
+
+func TestScoreSelector(t *testing.T) {
+	now := time.Now()
+	candidates := []*ClipHandle{
+		newTestHandle("a1", "a", now),
+		newTestHandle("b1", "b", now),
+	}
+
+	// only the clips of b score above zero
+	selector, err := NewScoreSelector(`broadcaster_id == "b" ? 1 : 0`)
+	require.NoError(t, err)
+
+	for range 10 {
+		require.Equal(t, "b1", selector.Select(candidates).clip.ID)
+	}
+
+	_, err = NewScoreSelector(`broadcaster_id`)
+	require.Error(t, err)
+}
+
+func TestScoreSelectorNotFinite(t *testing.T) {
+	now := time.Now()
+	// the last candidate is what a NaN total used to pick every time
+	candidates := []*ClipHandle{
+		newTestHandle("b1", "b", now),
+		newTestHandle("a1", "a", now),
+	}
+
+	for _, score := range []string{
+		`broadcaster_id == "a" ? float(view_count) / 0 : 1`,
+		`broadcaster_id == "a" ? float(view_count + 1) / 0 : 1`,
+		`broadcaster_id == "a" ? -float(view_count + 1) / 0 : 1`,
+	} {
+		selector, err := NewScoreSelector(score)
+		require.NoError(t, err)
+
+		for range 10 {
+			require.Equal(t, "b1", selector.Select(candidates).clip.ID, score)
+		}
+	}
+}
@@ -2,14 +2,20 @@ package clips
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"k0pern1cus/app/client/clip_downloader"
 	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/app/service/encoder"
+	"k0pern1cus/app/service/history"
 	"k0pern1cus/pkg/config"
 	"log/slog"
 	"math/rand"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -17,44 +23,71 @@ import (
 )
 
 var pageSize = 100
-var rateLimitInterval = 3 * time.Second
 var timeWindow = 24 * time.Hour * 30 * 5 // 5 months
 
+// fetchRetryInterval is waited after a failed page, the client has already retried transient failures
+var fetchRetryInterval = 3 * time.Second
+
 type Service struct {
 	cfg        *config.Config
 	client     *twitch.Client
 	downloader *clip_downloader.Downloader
+	encoder    *encoder.Service
+	history    *history.Service
+	selector   Selector
+	filter     clipFilter
+	// handleSeq numbers the handles, a clip may be replayed while an earlier handle of it is still on air
+	handleSeq atomic.Int64
 
 	m            sync.RWMutex
 	clips        map[string]*ClipHandle
 	initialized  bool
 	initComplete chan struct{}
 
-	rateLimiter chan struct{}
+	catalog map[string]twitch.Clip
+	// filtered maps the ids of the clips that did not pass the filter to the rule that rejected them
+	filtered        map[string]string
+	removedAt       map[string]time.Time
+	latestCreatedAt map[string]time.Time
+	playlist        []twitch.Clip
+	playlistPos     int
 }
 
 func New(di *do.Injector) (*Service, error) {
 	cfg := do.MustInvoke[*config.Config](di)
 
-	rateLimiter := make(chan struct{}, 1)
-	rateLimiter <- struct{}{}
+	var selector Selector
+	var err error
 
-	go func() {
-		ticker := time.NewTicker(rateLimitInterval)
-		defer ticker.Stop()
+	if cfg.Clips.Selector == SelectorScore {
+		selector, err = NewScoreSelector(cfg.Clips.Score)
+	} else {
+		selector, err = NewSelector(cfg.Clips.Selector)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create selector: %w", err)
+	}
 
-		for range ticker.C {
-			rateLimiter <- struct{}{}
-		}
-	}()
+	filter, err := newClipFilter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create filter: %w", err)
+	}
 
 	return &Service{
 		cfg:          cfg,
 		client:       do.MustInvoke[*twitch.Client](di),
 		downloader:   do.MustInvoke[*clip_downloader.Downloader](di),
+		encoder:      do.MustInvoke[*encoder.Service](di),
+		history:      do.MustInvoke[*history.Service](di),
+		selector:     selector,
+		filter:       filter,
 		clips:        make(map[string]*ClipHandle),
 		initComplete: make(chan struct{}),
-		rateLimiter:  rateLimiter,
+		catalog:      make(map[string]twitch.Clip),
+		removedAt:    make(map[string]time.Time),
+		filtered:     make(map[string]string),
+
+		latestCreatedAt: make(map[string]time.Time),
 	}, nil
 }
 
@@ -64,10 +97,15 @@ func (s *Service) Init(ctx context.Context) error {
 
 	slog.Debug("Initializing clips...")
 
-	minDate, err := time.Parse("January 2, 2006", s.cfg.Twitch.MinDate)
+	minDate, err := s.minDate()
 	if err != nil {
 		sentry.CaptureException(err)
-		return fmt.Errorf("could not parse account creation_date: %v", err)
+		return err
+	}
+
+	if err = s.loadPlaylist(ctx); err != nil {
+		sentry.CaptureException(err)
+		return fmt.Errorf("could not load fallback playlist: %w", err)
 	}
 
 	go s.backgroundFetchAllClips(ctx, minDate)
@@ -81,10 +119,39 @@ func (s *Service) Init(ctx context.Context) error {
 	}
 }
 
+// Fetch loads the whole catalog and returns once it is done, the catalog is not refreshed afterwards
+func (s *Service) Fetch(ctx context.Context) error {
+	span := sentry.StartSpan(ctx, "clips.fetch")
+	defer span.Finish()
+
+	minDate, err := s.minDate()
+	if err != nil {
+		return err
+	}
+
+	s.fetchAllClips(ctx, minDate)
+
+	return ctx.Err()
+}
+
+func (s *Service) minDate() (time.Time, error) {
+	minDate, err := time.Parse("January 2, 2006", s.cfg.Twitch.MinDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse account creation_date: %v", err)
+	}
+
+	return minDate, nil
+}
+
 func (s *Service) backgroundFetchAllClips(ctx context.Context, minDate time.Time) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	s.fetchAllClips(ctx, minDate)
+	s.refreshLoop(ctx)
+}
+
+func (s *Service) fetchAllClips(ctx context.Context, minDate time.Time) {
 	rand.Shuffle(len(s.cfg.Twitch.BroadcasterIDs), func(i, j int) {
 		s.cfg.Twitch.BroadcasterIDs[i], s.cfg.Twitch.BroadcasterIDs[j] = s.cfg.Twitch.BroadcasterIDs[j], s.cfg.Twitch.BroadcasterIDs[i]
 	})
@@ -124,6 +191,7 @@ func (s *Service) backgroundFetchAllClips(ctx context.Context, minDate time.Time
 	slog.Info("Initialized clips successfully",
 		slog.Int("count", len(s.clips)),
 		slog.Float64("duration", totalDuration),
+		slog.Any("filtered", s.filteredCounts()),
 	)
 	s.m.Unlock()
 }
@@ -146,104 +214,222 @@ func (s *Service) fetchBroadcasterClips(ctx context.Context, broadcasterID strin
 	startedAt := endedAt.Add(-timeWindow)
 
 	for {
-		var after string
+		if !s.fetchWindow(ctx, localHub, broadcasterID, startedAt, endedAt, workerID) {
+			return
+		}
 
-		for {
-			select {
-			case <-s.rateLimiter:
-			case <-ctx.Done():
-				return
-			}
+		endedAt = startedAt
+		startedAt = endedAt.Add(-timeWindow)
 
-			slog.Debug("Getting clips...",
-				slog.String("broadcaster_id", broadcasterID),
-				slog.Time("started_at", startedAt),
-				slog.Time("ended_at", endedAt),
-				slog.String("after", after),
-				slog.Int("worker_id", workerID),
-			)
+		if endedAt.Before(minDate) {
+			break
+		}
+	}
+}
 
-			res, err := s.client.GetClips(ctx, &twitch.GetClipsParams{
-				BroadcasterID: broadcasterID,
-				First:         pageSize,
-				StartedAt:     startedAt,
-				EndedAt:       endedAt,
-				After:         after,
-			})
-			if err != nil {
+// fetchWindow loads every page of broadcaster clips created within the given window, returns false if the context is done
+func (s *Service) fetchWindow(ctx context.Context, localHub *sentry.Hub, broadcasterID string, startedAt, endedAt time.Time, workerID int) bool {
+	var after string
+
+	// the client shares the Helix rate limit between the workers
+	for {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		slog.Debug("Getting clips...",
+			slog.String("broadcaster_id", broadcasterID),
+			slog.Time("started_at", startedAt),
+			slog.Time("ended_at", endedAt),
+			slog.String("after", after),
+			slog.Int("worker_id", workerID),
+		)
+
+		res, err := s.client.GetClips(ctx, &twitch.GetClipsParams{
+			BroadcasterID: broadcasterID,
+			First:         pageSize,
+			StartedAt:     startedAt,
+			EndedAt:       endedAt,
+			After:         after,
+		})
+		if err != nil {
+			// retrying a request Twitch refused as is only fails the same way
+			var apiErr *twitch.APIError
+			if errors.As(err, &apiErr) && !apiErr.Retryable && !apiErr.Unauthorized() {
 				localHub.CaptureException(err)
-				slog.Error("Failed to get clips",
+				slog.Error("Twitch refused the clips request, skipping the window",
 					slog.String("error", err.Error()),
 					slog.String("broadcaster_id", broadcasterID),
 					slog.Int("worker_id", workerID),
 				)
-				continue
+				return true
 			}
 
-			if len(res.Data) == 0 {
-				break
-			}
+			localHub.CaptureException(err)
+			slog.Error("Failed to get clips",
+				slog.String("error", err.Error()),
+				slog.String("broadcaster_id", broadcasterID),
+				slog.Int("worker_id", workerID),
+			)
 
-			newClips := make([]*ClipHandle, 0)
-			for _, clip := range res.Data {
-				if clip.GameID != s.cfg.Twitch.GameID {
-					continue
-				}
-
-				clipHandle := &ClipHandle{
-					clip:       clip,
-					downloader: s.downloader,
-					readyChan:  make(chan struct{}),
-				}
-				newClips = append(newClips, clipHandle)
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(fetchRetryInterval):
 			}
+			continue
+		}
 
-			if len(newClips) > 0 {
-				s.m.Lock()
-				for _, clipHandle := range newClips {
-					s.clips[clipHandle.clip.ID] = clipHandle
-				}
-
-				if !s.initialized {
-					s.initialized = true
-					close(s.initComplete)
-				}
-				s.m.Unlock()
-			}
+		if len(res.Data) == 0 {
+			return true
+		}
 
-			if res.Pagination.Cursor == "" {
-				break
-			}
+		s.addClips(res.Data)
 
-			after = res.Pagination.Cursor
+		if res.Pagination == nil || res.Pagination.Cursor == "" {
+			return true
 		}
 
-		endedAt = startedAt
-		startedAt = endedAt.Add(-timeWindow)
+		after = res.Pagination.Cursor
+	}
+}
 
-		if endedAt.Before(minDate) {
-			break
+// addClips merges fetched clips into the pool, skipping the ones that were already seen, returns the number of new clips
+func (s *Service) addClips(fetched []twitch.Clip) int {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	added := 0
+
+	for _, clip := range fetched {
+		if clip.CreatedAt.After(s.latestCreatedAt[clip.BroadcasterID]) {
+			s.latestCreatedAt[clip.BroadcasterID] = clip.CreatedAt
+		}
+
+		if _, ok := s.catalog[clip.ID]; ok {
+			continue
+		}
+
+		if _, ok := s.filtered[clip.ID]; ok {
+			continue
+		}
+
+		if rule := s.filter.Check(clip); rule != "" {
+			s.filtered[clip.ID] = rule
+			continue
+		}
+
+		if _, ok := s.history.Rejected(clip.ID); ok && s.cfg.Quality.Enabled {
+			continue
 		}
+		s.catalog[clip.ID] = clip
+
+		s.clips[clip.ID] = s.newHandle(clip)
+		added++
 	}
+
+	if added > 0 && !s.initialized {
+		s.initialized = true
+		close(s.initComplete)
+	}
+
+	return added
 }
 
 func (s *Service) RemoveClip() (*ClipHandle, bool) {
 	s.m.Lock()
 	defer s.m.Unlock()
 
-	if len(s.clips) == 0 {
+	candidates := make([]*ClipHandle, 0, len(s.clips))
+	for key, clip := range s.clips {
+		if s.history.InCooldown(key) {
+			continue
+		}
+
+		candidates = append(candidates, clip)
+	}
+
+	clip := s.selector.Select(candidates)
+	if clip == nil {
+		clip = s.handleExhaustion()
+	}
+	if clip == nil {
 		return nil, false
 	}
 
-	keys := make([]string, 0, len(s.clips))
-	for key := range s.clips {
-		keys = append(keys, key)
+	delete(s.clips, clip.clip.ID)
+	s.removedAt[clip.clip.ID] = time.Now()
+
+	return clip, true
+}
+
+// Reject records that the clip failed the quality gate and keeps it out of the rotation for the rest of the run
+func (s *Service) Reject(clip *ClipHandle, reason string) error {
+	s.m.Lock()
+	delete(s.catalog, clip.clip.ID)
+	delete(s.clips, clip.clip.ID)
+	s.m.Unlock()
+
+	return s.history.Reject(clip.clip.ID, reason)
+}
+
+func (s *Service) newHandle(clip twitch.Clip) *ClipHandle {
+	return &ClipHandle{
+		cfg:        s.cfg,
+		clip:       clip,
+		name:       fmt.Sprintf("%s_%d", clip.ID, s.handleSeq.Add(1)),
+		downloader: s.downloader,
+		encoder:    s.encoder,
+		readyChan:  make(chan struct{}),
+	}
+}
+
+// Catalog returns the clips left in the pool, the newest first
+func (s *Service) Catalog() []twitch.Clip {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	result := make([]twitch.Clip, 0, len(s.clips))
+	for _, clip := range s.clips {
+		result = append(result, clip.Clip())
 	}
 
-	randomKey := keys[rand.Intn(len(keys))]
+	slices.SortFunc(result, func(a, b twitch.Clip) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
 
-	clip := s.clips[randomKey]
-	delete(s.clips, randomKey)
+		return strings.Compare(a.ID, b.ID)
+	})
 
-	return clip, true
+	return result
+}
+
+// FilterStats returns the number of fetched clips rejected by each filter rule
+func (s *Service) FilterStats() map[string]int {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.filteredCounts()
+}
+
+func (s *Service) filteredCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, rule := range s.filtered {
+		counts[rule]++
+	}
+
+	return counts
+}
+
+func (s *Service) Stats() (int, float64) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	totalDuration := 0.0
+	for _, clip := range s.clips {
+		totalDuration += clip.Clip().Duration
+	}
+
+	return len(s.clips), totalDuration
 }
@@ -0,0 +1,69 @@
+package clips
+
+import (
+	"k0pern1cus/app/service/encoder"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// detected edges are a bit late for speech, keep some of the dead air around the cut
+var trimPadding = 0.1
+
+// intervals within this distance from the clip edge are considered to touch it
+var trimEdgeTolerance = 0.1
+
+// trim narrows the source down to the part worth airing
+func (h *ClipHandle) trim(source *encoder.ClipSource, analysis *encoder.Analysis) {
+	interval, ok := h.trimPoints(analysis)
+	if !ok {
+		return
+	}
+
+	h.trimmed = &interval
+	source.Trim = &interval
+	source.Duration = time.Duration(interval.Duration() * float64(time.Second))
+
+	slog.Debug("Clip trimmed",
+		slog.String("clip_id", h.clip.ID),
+		slog.Float64("start", interval.Start),
+		slog.Float64("end", interval.End),
+	)
+}
+
+// trimPoints cuts the leading silence and the trailing frozen or silent part of the clip,
+// returns false if there is nothing to cut or the rest would be too short
+func (h *ClipHandle) trimPoints(analysis *encoder.Analysis) (encoder.Interval, bool) {
+	cfg := &h.cfg.Trim
+	duration := analysis.Duration
+
+	result := encoder.Interval{
+		Start: 0,
+		End:   duration,
+	}
+
+	for _, interval := range analysis.Silent {
+		if interval.Start <= trimEdgeTolerance {
+			result.Start = max(result.Start, interval.End-trimPadding)
+		}
+	}
+
+	for _, interval := range slices.Concat(analysis.Silent, analysis.Frozen) {
+		if interval.End >= duration-trimEdgeTolerance {
+			result.End = min(result.End, interval.Start+trimPadding)
+		}
+	}
+
+	result.Start = min(result.Start, cfg.MaxStart)
+	result.End = max(result.End, duration-cfg.MaxEnd)
+
+	if result.Start <= 0 && result.End >= duration {
+		return encoder.Interval{}, false
+	}
+
+	if result.Duration() < cfg.MinDuration {
+		return encoder.Interval{}, false
+	}
+
+	return result, true
+}
@@ -0,0 +1,48 @@
+package clips
+
+import (
+	"k0pern1cus/app/service/encoder"
+	"k0pern1cus/pkg/config"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrimPoints(t *testing.T) {
+	var cfg config.Config
+	cfg.Trim.MaxStart = 3
+	cfg.Trim.MaxEnd = 5
+	cfg.Trim.MinDuration = 5
+
+	handle := &ClipHandle{cfg: &cfg}
+
+	interval, ok := handle.trimPoints(&encoder.Analysis{
+		Duration: 30,
+		Silent:   []encoder.Interval{{Start: 0, End: 1.1}, {Start: 10, End: 12}},
+		Frozen:   []encoder.Interval{{Start: 27, End: 30}},
+	})
+	require.True(t, ok)
+	require.InDelta(t, 1, interval.Start, 1e-9)
+	require.InDelta(t, 27.1, interval.End, 1e-9)
+
+	// long dead air is only cut up to the configured limits
+	interval, ok = handle.trimPoints(&encoder.Analysis{
+		Duration: 30,
+		Silent:   []encoder.Interval{{Start: 0, End: 10}, {Start: 15, End: 30}},
+	})
+	require.True(t, ok)
+	require.InDelta(t, 3, interval.Start, 1e-9)
+	require.InDelta(t, 25, interval.End, 1e-9)
+
+	_, ok = handle.trimPoints(&encoder.Analysis{
+		Duration: 30,
+		Silent:   []encoder.Interval{{Start: 10, End: 12}},
+	})
+	require.False(t, ok)
+
+	_, ok = handle.trimPoints(&encoder.Analysis{
+		Duration: 6,
+		Silent:   []encoder.Interval{{Start: 0, End: 2}},
+	})
+	require.False(t, ok)
+}
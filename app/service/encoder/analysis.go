@@ -0,0 +1,142 @@
+package encoder
+
+import (
+	"context"
+	"k0pern1cus/pkg/ffmpeg"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+var blackMinDuration = 0.5
+var blackPixelThreshold = 0.1
+var freezeNoise = "-60dB"
+var freezeMinDuration = 2.0
+var silenceNoise = "-50dB"
+var silenceMinDuration = 0.5
+
+// detection filters report the events in the log as e.g. "black_start:0", "freeze_end: 4.2" or "silence_start: 1.5"
+var detectionEventRegexp = regexp.MustCompile(`(black|freeze|silence)_(start|end)\s*:\s*(-?[0-9.]+)`)
+
+// Analyze decodes the whole clip once with black, freeze and silence detection
+func (s *Service) Analyze(ctx context.Context, input string, duration time.Duration, hasAudio bool) (*Analysis, error) {
+	span := sentry.StartSpan(ctx, "encoder.analyze")
+	defer span.Finish()
+
+	video := ffmpeg.NewChain(
+		ffmpeg.NewFilter("blackdetect").
+			Set("d", blackMinDuration).
+			Set("pix_th", blackPixelThreshold),
+		ffmpeg.NewFilter("freezedetect").
+			Set("n", freezeNoise).
+			Set("d", freezeMinDuration),
+	)
+
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-loglevel", "info",
+		"-i", input,
+		"-vf", video.String(),
+	}
+
+	if hasAudio {
+		audio := ffmpeg.NewChain(
+			ffmpeg.NewFilter("silencedetect").
+				Set("n", silenceNoise).
+				Set("d", silenceMinDuration),
+		)
+
+		args = append(args, "-af", audio.String())
+	}
+
+	args = append(args, "-f", "null", "-")
+
+	output, err := ffmpeg.Output(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseAnalysis(output, duration.Seconds()), nil
+}
+
+// parseAnalysis collects the detected intervals, the ones still open at the end of the log last until the end of the clip
+func parseAnalysis(output []byte, duration float64) *Analysis {
+	result := &Analysis{
+		Duration: duration,
+	}
+
+	add := func(kind string, interval Interval) {
+		switch kind {
+		case "black":
+			result.Black = append(result.Black, interval)
+		case "freeze":
+			result.Frozen = append(result.Frozen, interval)
+		case "silence":
+			result.Silent = append(result.Silent, interval)
+		}
+	}
+
+	opened := make(map[string]float64)
+
+	for _, match := range detectionEventRegexp.FindAllSubmatch(output, -1) {
+		kind := string(match[1])
+
+		value, err := strconv.ParseFloat(string(match[3]), 64)
+		if err != nil {
+			continue
+		}
+
+		if string(match[2]) == "start" {
+			opened[kind] = max(value, 0)
+			continue
+		}
+
+		start, ok := opened[kind]
+		if !ok {
+			continue
+		}
+		delete(opened, kind)
+
+		add(kind, Interval{Start: start, End: min(value, duration)})
+	}
+
+	for _, kind := range []string{"black", "freeze", "silence"} {
+		if start, ok := opened[kind]; ok && start < duration {
+			add(kind, Interval{Start: start, End: duration})
+		}
+	}
+
+	return result
+}
+
+func (i Interval) Duration() float64 {
+	return max(i.End-i.Start, 0)
+}
+
+func (a *Analysis) BlackRatio() float64 {
+	return a.ratio(a.Black)
+}
+
+func (a *Analysis) FrozenRatio() float64 {
+	return a.ratio(a.Frozen)
+}
+
+func (a *Analysis) SilentRatio() float64 {
+	return a.ratio(a.Silent)
+}
+
+func (a *Analysis) ratio(intervals []Interval) float64 {
+	if a.Duration <= 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, interval := range intervals {
+		total += interval.Duration()
+	}
+
+	return min(total/a.Duration, 1)
+}
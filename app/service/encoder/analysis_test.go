@@ -0,0 +1,29 @@
+package encoder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnalysis(t *testing.T) {
+	output := []byte(`[blackdetect @ 0x55d1c0] black_start:0 black_end:1.5 black_duration:1.5
+[silencedetect @ 0x55d1c1] silence_start: 2
+[silencedetect @ 0x55d1c1] silence_end: 3.5 | silence_duration: 1.5
+[freezedetect @ 0x55d1c2] lavfi.freezedetect.freeze_start: 7
+[freezedetect @ 0x55d1c2] lavfi.freezedetect.freeze_duration: 2
+[freezedetect @ 0x55d1c2] lavfi.freezedetect.freeze_end: 9
+[silencedetect @ 0x55d1c1] silence_start: 8
+[blackdetect @ 0x55d1c0] black_start:9.5
+`)
+
+	result := parseAnalysis(output, 10)
+
+	require.Equal(t, []Interval{{Start: 0, End: 1.5}, {Start: 9.5, End: 10}}, result.Black)
+	require.Equal(t, []Interval{{Start: 7, End: 9}}, result.Frozen)
+	require.Equal(t, []Interval{{Start: 2, End: 3.5}, {Start: 8, End: 10}}, result.Silent)
+
+	require.InDelta(t, 0.2, result.BlackRatio(), 1e-9)
+	require.InDelta(t, 0.2, result.FrozenRatio(), 1e-9)
+	require.InDelta(t, 0.35, result.SilentRatio(), 1e-9)
+}
@@ -0,0 +1,139 @@
+package encoder
+
+import (
+	"context"
+	"fmt"
+	"k0pern1cus/pkg/config"
+	"k0pern1cus/pkg/ffmpeg"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// backslash and percent sign are special in drawtext expansion
+var expansionEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`%`, `\%`,
+)
+
+// EncodeCard renders the card into a segment matching the active profile
+func (s *Service) EncodeCard(ctx context.Context, card config.Card, output string) error {
+	span := sentry.StartSpan(ctx, "encoder.encode_card")
+	defer span.Finish()
+
+	return s.encodeCard(ctx, card, card.Text, false, output)
+}
+
+// EncodeCountdown renders the card with a timer counting down to the end of it under the card text
+func (s *Service) EncodeCountdown(ctx context.Context, card config.Card, output string) error {
+	span := sentry.StartSpan(ctx, "encoder.encode_countdown")
+	defer span.Finish()
+
+	timer := fmt.Sprintf("%%{eif:trunc((%[1]s-t)/60):d:2}:%%{eif:mod(trunc(%[1]s-t),60):d:2}",
+		strconv.FormatFloat(card.Duration, 'f', 3, 64),
+	)
+
+	text := timer
+	if card.Text != "" {
+		text = expansionEscaper.Replace(card.Text) + "\n" + timer
+	}
+
+	return s.encodeCard(ctx, card, text, true, output)
+}
+
+func (s *Service) encodeCard(ctx context.Context, card config.Card, text string, expand bool, output string) error {
+	profile := s.cfg.ActiveProfile()
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-threads", "0",
+		"-y",
+	}
+
+	videoHasAudio := false
+
+	switch {
+	case card.Video != "":
+		probe, err := s.Probe(ctx, card.Video)
+		if err != nil {
+			return fmt.Errorf("probe: %w", err)
+		}
+		videoHasAudio = probe.HasAudio()
+
+		if card.Duration > 0 {
+			args = append(args, "-stream_loop", "-1")
+		}
+		args = append(args, "-i", card.Video)
+	case card.Image != "":
+		args = append(args, "-loop", "1", "-framerate", strconv.Itoa(profile.FPS), "-i", card.Image)
+	default:
+		color := ffmpeg.NewFilter("color").
+			Set("c", "black").
+			Set("s", fmt.Sprintf("%dx%d", profile.Width, profile.Height)).
+			Set("r", profile.FPS)
+
+		args = append(args, "-f", "lavfi", "-i", color.String())
+	}
+
+	audioInput := "1:a"
+
+	switch {
+	case card.Audio != "":
+		args = append(args, "-stream_loop", "-1", "-i", card.Audio)
+	case videoHasAudio:
+		audioInput = "0:a"
+	default:
+		args = append(args, "-f", "lavfi", "-i", silenceSource(profile).String())
+	}
+
+	video := ffmpeg.NewChain(scaleFilters(profile)...).From("0:v")
+
+	if text != "" {
+		textFile := output + ".txt"
+		if err := os.WriteFile(textFile, []byte(text), 0o644); err != nil {
+			return fmt.Errorf("write text: %w", err)
+		}
+		defer os.Remove(textFile)
+
+		expansion := "none"
+		if expand {
+			expansion = "normal"
+		}
+
+		video.Append(
+			ffmpeg.NewFilter("drawtext").
+				Set("textfile", textFile).
+				Set("expansion", expansion).
+				Set("fontfile", card.FontFile).
+				Set("fontsize", profile.Height/12).
+				Set("fontcolor", "white").
+				Set("line_spacing", profile.Height/48).
+				Set("x", "(w-text_w)/2").
+				Set("y", "(h-text_h)/2"),
+		)
+	}
+
+	args = append(args,
+		"-filter_complex", video.To("v").String(),
+		"-map", "[v]",
+		"-map", audioInput,
+	)
+
+	// the video is played once, the looped audio must not outlast it
+	if card.Duration > 0 {
+		args = append(args, "-t", strconv.FormatFloat(card.Duration, 'f', 3, 64))
+	} else {
+		args = append(args, "-shortest")
+	}
+
+	args = append(args, s.outputArgs(profile, output)...)
+
+	if err := ffmpeg.Run(ctx, "card", args...); err != nil {
+		return fmt.Errorf("encode card: %w", err)
+	}
+
+	return nil
+}
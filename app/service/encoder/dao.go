@@ -0,0 +1,64 @@
+package encoder
+
+import "time"
+
+// ProbeResult represents the parts of the ffprobe output we care about
+type ProbeResult struct {
+	Format  ProbeFormat   `json:"format"`
+	Streams []ProbeStream `json:"streams"`
+}
+
+// ProbeFormat represents the container information
+type ProbeFormat struct {
+	Duration string `json:"duration"`
+}
+
+// ProbeStream represents a single audio or video stream
+type ProbeStream struct {
+	CodecType    string `json:"codec_type"`
+	CodecName    string `json:"codec_name"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	AvgFrameRate string `json:"avg_frame_rate"`
+}
+
+// LoudnessMeasurement represents the first pass loudnorm statistics
+type LoudnessMeasurement struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// Interval is a time range in seconds from the start of the clip
+type Interval struct {
+	Start float64
+	End   float64
+}
+
+// Analysis represents the black, frozen and silent parts detected in a clip
+type Analysis struct {
+	Duration float64
+	Black    []Interval
+	Frozen   []Interval
+	Silent   []Interval
+}
+
+// ClipSource describes the downloaded clip and what is known about it before encoding,
+// duration is the length of the trimmed part if the source is trimmed
+type ClipSource struct {
+	Path     string
+	Duration time.Duration
+	Trim     *Interval
+	Loudness *LoudnessMeasurement
+}
+
+// ClipSegments are the files a clip is encoded into, head and tail are only produced for crossfades,
+// title is only produced if the title card is enabled
+type ClipSegments struct {
+	Title string
+	Body  string
+	Head  string
+	Tail  string
+}
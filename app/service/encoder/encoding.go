@@ -0,0 +1,43 @@
+package encoder
+
+import (
+	"fmt"
+	"k0pern1cus/pkg/config"
+	"strconv"
+)
+
+// videoEncodingArgs builds the CBR libx264 arguments for the profile
+func videoEncodingArgs(profile config.EncodingProfile) []string {
+	gop := strconv.Itoa(profile.KeyframeFrames())
+
+	args := []string{
+		"-c:v", "libx264",
+		"-preset", profile.Preset,
+	}
+
+	if profile.Tune != "" {
+		args = append(args, "-tune", profile.Tune)
+	}
+
+	return append(args,
+		"-profile:v", profile.H264Profile,
+		"-b:v", fmt.Sprintf("%dk", profile.VideoBitrate),
+		"-maxrate", fmt.Sprintf("%dk", profile.MaxBitrate),
+		"-minrate", fmt.Sprintf("%dk", profile.VideoBitrate),
+		"-bufsize", fmt.Sprintf("%dk", profile.BufferSize),
+		"-r", strconv.Itoa(profile.FPS),
+		"-g", gop,
+		"-keyint_min", gop,
+		"-pix_fmt", "yuv420p",
+		"-x264opts", "nal-hrd=cbr:force-cfr=1",
+	)
+}
+
+func audioEncodingArgs(profile config.EncodingProfile) []string {
+	return []string{
+		"-c:a", "aac",
+		"-b:a", fmt.Sprintf("%dk", profile.AudioBitrate),
+		"-ar", strconv.Itoa(profile.AudioRate),
+		"-ac", strconv.Itoa(profile.AudioChannels),
+	}
+}
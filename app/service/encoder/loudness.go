@@ -0,0 +1,91 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"k0pern1cus/pkg/config"
+	"k0pern1cus/pkg/ffmpeg"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// MeasureLoudness runs the first loudnorm pass over the clip audio
+func (s *Service) MeasureLoudness(ctx context.Context, source ClipSource) (*LoudnessMeasurement, error) {
+	span := sentry.StartSpan(ctx, "encoder.measure_loudness")
+	defer span.Finish()
+
+	loudnorm := loudnormFilter(s.cfg).Set("print_format", "json")
+
+	// loudnorm prints the statistics on the info level
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-loglevel", "info",
+	}
+	args = append(args, source.inputArgs()...)
+	args = append(args,
+		"-vn",
+		"-af", loudnorm.String(),
+		"-f", "null",
+		"-",
+	)
+
+	output, err := ffmpeg.Output(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLoudness(output)
+}
+
+// parseLoudness extracts the first pass statistics loudnorm prints after the rest of the ffmpeg output
+func parseLoudness(output []byte) (*LoudnessMeasurement, error) {
+	begin := bytes.LastIndexByte(output, '{')
+	end := bytes.LastIndexByte(output, '}')
+	if begin < 0 || end < begin {
+		return nil, fmt.Errorf("no loudnorm statistics found in ffmpeg output")
+	}
+
+	var result LoudnessMeasurement
+	if err := json.Unmarshal(output[begin:end+1], &result); err != nil {
+		return nil, fmt.Errorf("parse loudnorm statistics: %w", err)
+	}
+
+	// silent clips can not be normalized
+	if strings.Contains(result.InputI, "inf") || strings.Contains(result.InputThresh, "inf") {
+		return nil, fmt.Errorf("clip is silent: integrated loudness %s", result.InputI)
+	}
+
+	return &result, nil
+}
+
+// audioFilters normalize the loudness in a single linear pass using the first pass statistics
+func (s *Service) audioFilters(loudness *LoudnessMeasurement) []*ffmpeg.Filter {
+	if !s.cfg.Audio.Loudnorm.Enabled || loudness == nil {
+		return []*ffmpeg.Filter{ffmpeg.NewFilter("anull")}
+	}
+
+	loudnorm := loudnormFilter(s.cfg).
+		Set("measured_I", loudness.InputI).
+		Set("measured_TP", loudness.InputTP).
+		Set("measured_LRA", loudness.InputLRA).
+		Set("measured_thresh", loudness.InputThresh).
+		Set("offset", loudness.TargetOffset).
+		Set("linear", "true")
+
+	// loudnorm upsamples to 192 kHz internally
+	return []*ffmpeg.Filter{
+		loudnorm,
+		ffmpeg.NewFilter("aresample").Set("out_sample_rate", s.cfg.ActiveProfile().AudioRate),
+	}
+}
+
+func loudnormFilter(cfg *config.Config) *ffmpeg.Filter {
+	return ffmpeg.NewFilter("loudnorm").
+		Set("I", cfg.Audio.Loudnorm.TargetI).
+		Set("TP", cfg.Audio.Loudnorm.TargetTP).
+		Set("LRA", cfg.Audio.Loudnorm.TargetLRA)
+}
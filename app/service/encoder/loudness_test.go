@@ -0,0 +1,66 @@
+package encoder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLoudness(t *testing.T) {
+	output := []byte(`Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'data/AbcDef_1.mp4':
+  Duration: 00:00:29.98, start: 0.000000, bitrate: 6213 kb/s
+  Stream #0:1[0x2](und): Audio: aac (LC) (mp4a / 0x6134706D), 48000 Hz, stereo, fltp, 159 kb/s (default)
+Stream mapping:
+  Stream #0:1 -> #0:0 (aac (native) -> pcm_s16le (native))
+Output #0, null, to 'pipe:':
+  Stream #0:0(und): Audio: pcm_s16le, 192000 Hz, stereo, s16, 6144 kb/s (default)
+[Parsed_loudnorm_0 @ 0x5590d6f6c2c0] 
+{
+	"input_i" : "-23.47",
+	"input_tp" : "-4.15",
+	"input_lra" : "6.90",
+	"input_thresh" : "-33.93",
+	"output_i" : "-16.42",
+	"output_tp" : "-1.50",
+	"output_lra" : "5.20",
+	"output_thresh" : "-26.80",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.42"
+}
+[out#0/null @ 0x5590d6f3a9c0] video:0KiB audio:22488KiB subtitle:0KiB other streams:0KiB global headers:0KiB muxing overhead: unknown
+size=N/A time=00:00:29.98 bitrate=N/A speed= 112x
+`)
+
+	result, err := parseLoudness(output)
+	require.NoError(t, err)
+	require.Equal(t, &LoudnessMeasurement{
+		InputI:       "-23.47",
+		InputTP:      "-4.15",
+		InputLRA:     "6.90",
+		InputThresh:  "-33.93",
+		TargetOffset: "0.42",
+	}, result)
+}
+
+func TestParseLoudnessFallback(t *testing.T) {
+	// the clip is encoded without normalization whenever the statistics can not be used
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{"no statistics", "[out#0/null @ 0x5590d6f3a9c0] video:0KiB audio:22488KiB\n"},
+		{"empty output", ""},
+		{"truncated json", "[Parsed_loudnorm_0 @ 0x5590d6f6c2c0] \n{\n\t\"input_i\" : \"-23.47\",\n"},
+		{"malformed json", "[Parsed_loudnorm_0 @ 0x5590d6f6c2c0] \n{\n\t\"input_i\" : -23.47 -4.15\n}\n"},
+		{"wrong field type", "{\"input_i\" : -23.47}"},
+		{"silent clip", "{\n\t\"input_i\" : \"-inf\",\n\t\"input_thresh\" : \"-inf\",\n\t\"target_offset\" : \"inf\"\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseLoudness([]byte(tt.output))
+			require.Error(t, err)
+			require.Nil(t, result)
+		})
+	}
+}
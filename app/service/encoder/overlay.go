@@ -0,0 +1,190 @@
+package encoder
+
+import (
+	"fmt"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/pkg/config"
+	"k0pern1cus/pkg/ffmpeg"
+	"k0pern1cus/pkg/util"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var overlayFuncs = template.FuncMap{
+	"timeAgo":  util.TimeAgo,
+	"truncate": func(n int, s string) string { return util.TrimSuffixToNRunes(s, n) },
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
+
+type overlayLayer struct {
+	config.OverlayLayer
+	template *template.Template
+}
+
+func parseOverlayLayers(layers []config.OverlayLayer) ([]overlayLayer, error) {
+	result := make([]overlayLayer, 0, len(layers))
+
+	for i, layer := range layers {
+		tmpl, err := parseClipTemplate(fmt.Sprintf("layer_%d", i), layer.Text)
+		if err != nil {
+			return nil, fmt.Errorf("overlay layer %d: %w", i, err)
+		}
+
+		result = append(result, overlayLayer{
+			OverlayLayer: layer,
+			template:     tmpl,
+		})
+	}
+
+	return result, nil
+}
+
+// parseClipTemplate parses a text template rendered against the clip fields
+func parseClipTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).
+		Funcs(overlayFuncs).
+		Option("missingkey=error").
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+
+	// execute against an empty clip to catch unknown fields before going on air
+	if err = tmpl.Execute(&strings.Builder{}, overlayData(twitch.Clip{}, "")); err != nil {
+		return nil, fmt.Errorf("execute: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// overlayData exposes the clip fields to the templates under their API names
+func overlayData(clip twitch.Clip, gameName string) map[string]any {
+	clip.Title = singleLine(clip.Title)
+	clip.BroadcasterName = singleLine(clip.BroadcasterName)
+	clip.CreatorName = singleLine(clip.CreatorName)
+	gameName = singleLine(gameName)
+
+	return map[string]any{
+		"id":               clip.ID,
+		"url":              clip.URL,
+		"broadcaster_id":   clip.BroadcasterID,
+		"broadcaster_name": clip.BroadcasterName,
+		"creator_id":       clip.CreatorID,
+		"creator_name":     clip.CreatorName,
+		"video_id":         clip.VideoID,
+		"game_id":          clip.GameID,
+		"game":             gameName,
+		"language":         clip.Language,
+		"title":            clip.Title,
+		"view_count":       clip.ViewCount,
+		"created_at":       clip.CreatedAt,
+		"thumbnail_url":    clip.ThumbnailURL,
+		"duration":         clip.Duration,
+		"vod_offset":       clip.VodOffset,
+		"is_featured":      clip.IsFeatured,
+	}
+}
+
+// singleLine keeps line breaks coming from Twitch from spreading the overlay over several lines
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func (l *overlayLayer) position() (string, string) {
+	margin := l.Margin
+
+	var x, y string
+
+	switch l.Position {
+	case "top_left", "bottom_left":
+		x = fmt.Sprintf("%d", margin)
+	case "top_center", "center", "bottom_center":
+		x = "(w-text_w)/2"
+	default:
+		x = fmt.Sprintf("w-text_w-%d", margin)
+	}
+
+	switch l.Position {
+	case "center":
+		y = "(h-text_h)/2"
+	case "bottom_left", "bottom_center", "bottom_right":
+		y = fmt.Sprintf("h-text_h-%d", margin)
+	default:
+		y = fmt.Sprintf("%d", margin)
+	}
+
+	if l.X != "" {
+		x = l.X
+	}
+	if l.Y != "" {
+		y = l.Y
+	}
+
+	return x, y
+}
+
+// enableExpr returns the timeline expression for the layer, empty if it is shown for the whole clip
+func (l *overlayLayer) enableExpr(clipDuration float64) string {
+	hideAt := l.HideAt
+	if hideAt < 0 {
+		hideAt += clipDuration
+	}
+
+	switch {
+	case hideAt > 0:
+		return fmt.Sprintf("between(t,%.2f,%.2f)", l.ShowAt, hideAt)
+	case l.ShowAt > 0:
+		return fmt.Sprintf("gte(t,%.2f)", l.ShowAt)
+	default:
+		return ""
+	}
+}
+
+// renderText executes the layer template against the clip
+func (l *overlayLayer) renderText(clip twitch.Clip, gameName string) (string, error) {
+	var text strings.Builder
+	if err := l.template.Execute(&text, overlayData(clip, gameName)); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+
+	return text.String(), nil
+}
+
+// drawtextFilter builds the drawtext filter that reads the rendered text from textFile as is
+func (l *overlayLayer) drawtextFilter(textFile string, clipDuration float64) *ffmpeg.Filter {
+	x, y := l.position()
+
+	filter := ffmpeg.NewFilter("drawtext").
+		Set("textfile", textFile).
+		Set("expansion", "none").
+		Set("fontfile", l.FontFile).
+		Set("x", x).
+		Set("y", y).
+		Set("fontsize", l.FontSize).
+		Set("fontcolor", l.FontColor)
+
+	if l.ShadowColor != "" && l.ShadowOffset > 0 {
+		filter.
+			Set("shadowcolor", l.ShadowColor).
+			Set("shadowx", l.ShadowOffset).
+			Set("shadowy", l.ShadowOffset)
+	}
+
+	if l.Box {
+		filter.
+			Set("box", true).
+			Set("boxcolor", l.BoxColor).
+			Set("boxborderw", l.BoxBorder)
+	}
+
+	if enable := l.enableExpr(clipDuration); enable != "" {
+		filter.Set("enable", enable)
+	}
+
+	return filter
+}
@@ -0,0 +1,104 @@
+package encoder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+func (s *Service) Probe(ctx context.Context, path string) (*ProbeResult, error) {
+	span := sentry.StartSpan(ctx, "encoder.probe")
+	defer span.Finish()
+
+	args := []string{
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var result ProbeResult
+	if err = json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (s *Service) ProbeDuration(ctx context.Context, path string) (time.Duration, error) {
+	result, err := s.Probe(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Duration()
+}
+
+func (r *ProbeResult) Duration() (time.Duration, error) {
+	if r.Format.Duration == "" {
+		return 0, fmt.Errorf("no duration found in ffprobe output")
+	}
+
+	durationSec, err := strconv.ParseFloat(r.Format.Duration, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse duration: %w", err)
+	}
+
+	return time.Duration(durationSec * float64(time.Second)), nil
+}
+
+func (r *ProbeResult) HasAudio() bool {
+	for _, stream := range r.Streams {
+		if stream.CodecType == "audio" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VideoStream returns the first video stream, nil if there is none
+func (r *ProbeResult) VideoStream() *ProbeStream {
+	for i := range r.Streams {
+		if r.Streams[i].CodecType == "video" {
+			return &r.Streams[i]
+		}
+	}
+
+	return nil
+}
+
+// FrameRate parses the average frame rate fraction, zero if it is unknown
+func (s *ProbeStream) FrameRate() float64 {
+	num, den, found := strings.Cut(s.AvgFrameRate, "/")
+
+	numerator, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+
+	if !found {
+		return numerator
+	}
+
+	denominator, err := strconv.ParseFloat(den, 64)
+	if err != nil || denominator == 0 {
+		return 0
+	}
+
+	return numerator / denominator
+}
@@ -0,0 +1,362 @@
+package encoder
+
+import (
+	"context"
+	"fmt"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/pkg/config"
+	"k0pern1cus/pkg/ffmpeg"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/samber/do"
+)
+
+type Service struct {
+	cfg    *config.Config
+	client *twitch.Client
+
+	overlayLayers []overlayLayer
+	titleCard     *template.Template
+}
+
+func New(di *do.Injector) (*Service, error) {
+	cfg := do.MustInvoke[*config.Config](di)
+
+	overlayLayers, err := parseOverlayLayers(cfg.Overlay.Layers)
+	if err != nil {
+		return nil, fmt.Errorf("parse overlay: %w", err)
+	}
+
+	titleCard, err := parseClipTemplate("title_card", cfg.TitleCard.Text)
+	if err != nil {
+		return nil, fmt.Errorf("parse title card: %w", err)
+	}
+
+	return &Service{
+		cfg:           cfg,
+		client:        do.MustInvoke[*twitch.Client](di),
+		overlayLayers: overlayLayers,
+		titleCard:     titleCard,
+	}, nil
+}
+
+// EncodeClip transcodes the downloaded clip into ready to air mpegts segments with timestamps starting at zero.
+// In crossfade mode the first and the last transition.duration seconds go to separate head and tail segments,
+// so that they can be blended with the neighbouring clips later
+func (s *Service) EncodeClip(ctx context.Context, clip twitch.Clip, source ClipSource, out ClipSegments) (ClipSegments, error) {
+	span := sentry.StartSpan(ctx, "encoder.encode_clip")
+	defer span.Finish()
+
+	span.SetTag("clip_id", clip.ID)
+
+	beginTime := time.Now()
+
+	probe, err := s.Probe(ctx, source.Path)
+	if err != nil {
+		return ClipSegments{}, fmt.Errorf("probe: %w", err)
+	}
+
+	profile := s.cfg.ActiveProfile()
+	duration := source.Duration.Seconds()
+	transitionDuration := s.cfg.Transition.Duration
+
+	video := ffmpeg.NewChain().From("0:v")
+
+	if s.cfg.Transition.Mode == config.TransitionFade {
+		fadeoutStart := max(duration-transitionDuration, 0)
+
+		video.Append(
+			ffmpeg.NewFilter("fade").Set("t", "in").Set("st", 0.0).Set("d", transitionDuration),
+			ffmpeg.NewFilter("fade").Set("t", "out").Set("st", fadeoutStart).Set("d", transitionDuration),
+		)
+	}
+
+	video.Append(scaleFilters(profile)...)
+
+	overlayFilters, textFiles, err := s.overlayFilters(ctx, clip, out.Body, duration)
+	defer removeFiles(textFiles)
+	if err != nil {
+		return ClipSegments{}, fmt.Errorf("build overlay: %w", err)
+	}
+	video.Append(overlayFilters...)
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-threads", "0",
+		"-y",
+	}
+	args = append(args, source.inputArgs()...)
+
+	audio := ffmpeg.NewChain(s.audioFilters(source.Loudness)...).From("0:a")
+
+	// a clip without audio gets a silent track as long as the clip, so the audio splits and crossfades work the same
+	if !probe.HasAudio() {
+		args = append(args, "-f", "lavfi", "-i", silenceSource(profile).String())
+
+		audio = ffmpeg.NewChain(ffmpeg.NewFilter("atrim").Set("end", duration)).From("1:a")
+	}
+
+	result := ClipSegments{
+		Body: out.Body,
+	}
+
+	// a clip shorter than two transitions and a bit of body is played with hard cuts
+	if s.cfg.Transition.Mode == config.TransitionCrossfade && duration >= 3*transitionDuration {
+		bodyEnd := duration - transitionDuration
+
+		video.Append(
+			ffmpeg.NewFilter("fps").Set("fps", profile.FPS),
+			ffmpeg.NewFilter("split").Set("outputs", 3),
+		).To("vh", "vb", "vt")
+
+		graph := ffmpeg.Graph{
+			video,
+			trimChain("vh", "vho", "trim", 0, transitionDuration),
+			trimChain("vb", "vbo", "trim", transitionDuration, bodyEnd),
+			trimChain("vt", "vto", "trim", bodyEnd, 0),
+			audio.Append(ffmpeg.NewFilter("asplit").Set("outputs", 3)).To("ah", "ab", "at"),
+			trimChain("ah", "aho", "atrim", 0, transitionDuration),
+			trimChain("ab", "abo", "atrim", transitionDuration, bodyEnd),
+			trimChain("at", "ato", "atrim", bodyEnd, 0),
+		}
+
+		args = append(args, "-filter_complex", graph.String())
+		args = append(args, "-map", "[vho]", "-map", "[aho]")
+		args = append(args, s.outputArgs(profile, out.Head)...)
+		args = append(args, "-map", "[vbo]", "-map", "[abo]")
+		args = append(args, s.outputArgs(profile, out.Body)...)
+		args = append(args, "-map", "[vto]", "-map", "[ato]")
+		args = append(args, s.outputArgs(profile, out.Tail)...)
+
+		result.Head = out.Head
+		result.Tail = out.Tail
+	} else {
+		graph := ffmpeg.Graph{
+			video.To("v"),
+			audio.To("a"),
+		}
+
+		args = append(args, "-filter_complex", graph.String())
+		args = append(args, "-map", "[v]", "-map", "[a]")
+		args = append(args, s.outputArgs(profile, out.Body)...)
+	}
+
+	if err = ffmpeg.Run(ctx, clip.ID, args...); err != nil {
+		return ClipSegments{}, fmt.Errorf("encode clip: %w", err)
+	}
+
+	slog.Debug("Clip encoding finished",
+		slog.String("clip_id", clip.ID),
+		slog.Bool("crossfade", result.Head != ""),
+		slog.Bool("loudnorm", source.Loudness != nil),
+		slog.Duration("exec_time", time.Since(beginTime)),
+	)
+
+	return result, nil
+}
+
+// EncodeTransition blends the tail of one clip into the head of the next one
+func (s *Service) EncodeTransition(ctx context.Context, tail, head, output string) error {
+	span := sentry.StartSpan(ctx, "encoder.encode_transition")
+	defer span.Finish()
+
+	profile := s.cfg.ActiveProfile()
+	transitionDuration := s.cfg.Transition.Duration
+
+	graph := ffmpeg.Graph{
+		ffmpeg.NewChain(
+			ffmpeg.NewFilter("xfade").
+				Set("transition", s.cfg.Transition.Effect).
+				Set("duration", transitionDuration).
+				Set("offset", 0.0),
+		).From("0:v", "1:v").To("v"),
+		ffmpeg.NewChain(
+			ffmpeg.NewFilter("acrossfade").Set("d", transitionDuration),
+		).From("0:a", "1:a").To("a"),
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-threads", "0",
+		"-y",
+		"-i", tail,
+		"-i", head,
+		"-filter_complex", graph.String(),
+		"-map", "[v]",
+		"-map", "[a]",
+	}
+	args = append(args, s.outputArgs(profile, output)...)
+
+	if err := ffmpeg.Run(ctx, "transition", args...); err != nil {
+		return fmt.Errorf("encode transition: %w", err)
+	}
+
+	return nil
+}
+
+// EncodeVideo converts an arbitrary local video into a segment matching the active profile,
+// silence is added if the video has no audio
+func (s *Service) EncodeVideo(ctx context.Context, input, output string) error {
+	span := sentry.StartSpan(ctx, "encoder.encode_video")
+	defer span.Finish()
+
+	profile := s.cfg.ActiveProfile()
+
+	probe, err := s.Probe(ctx, input)
+	if err != nil {
+		return fmt.Errorf("probe: %w", err)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-threads", "0",
+		"-y",
+		"-i", input,
+		"-f", "lavfi",
+		"-i", silenceSource(profile).String(),
+		"-filter_complex", ffmpeg.NewChain(scaleFilters(profile)...).From("0:v").To("v").String(),
+		"-map", "[v]",
+	}
+
+	if probe.HasAudio() {
+		args = append(args, "-map", "0:a")
+	} else {
+		args = append(args, "-map", "1:a", "-shortest")
+	}
+
+	args = append(args, s.outputArgs(profile, output)...)
+
+	if err = ffmpeg.Run(ctx, input, args...); err != nil {
+		return fmt.Errorf("encode video: %w", err)
+	}
+
+	return nil
+}
+
+// inputArgs seek to the trimmed part of the source, the timestamps of the decoded frames start at zero
+func (src ClipSource) inputArgs() []string {
+	if src.Trim == nil {
+		return []string{"-i", src.Path}
+	}
+
+	return []string{
+		"-ss", strconv.FormatFloat(src.Trim.Start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(src.Trim.Duration(), 'f', 3, 64),
+		"-i", src.Path,
+	}
+}
+
+func (s *Service) outputArgs(profile config.EncodingProfile, output string) []string {
+	args := videoEncodingArgs(profile)
+	args = append(args, audioEncodingArgs(profile)...)
+
+	return append(args,
+		"-f", "mpegts",
+		output,
+	)
+}
+
+// trimChain cuts [start, end) out of the labeled stream and resets its timestamps, zero end means until the end
+func trimChain(input, output, filter string, start, end float64) *ffmpeg.Chain {
+	trim := ffmpeg.NewFilter(filter)
+	if start > 0 {
+		trim.Set("start", start)
+	}
+	if end > 0 {
+		trim.Set("end", end)
+	}
+
+	setpts := "setpts"
+	if filter == "atrim" {
+		setpts = "asetpts"
+	}
+
+	return ffmpeg.NewChain(trim, ffmpeg.NewFilter(setpts).Set("expr", "PTS-STARTPTS")).From(input).To(output)
+}
+
+func silenceSource(profile config.EncodingProfile) *ffmpeg.Filter {
+	channelLayout := "stereo"
+	if profile.AudioChannels == 1 {
+		channelLayout = "mono"
+	}
+
+	return ffmpeg.NewFilter("anullsrc").
+		Set("channel_layout", channelLayout).
+		Set("sample_rate", profile.AudioRate)
+}
+
+// overlayFilters renders the overlay layers into text files next to the output, so that
+// no clip title can break the filtergraph or be interpreted as a drawtext expansion
+func (s *Service) overlayFilters(ctx context.Context, clip twitch.Clip, output string, duration float64) ([]*ffmpeg.Filter, []string, error) {
+	if len(s.overlayLayers) == 0 {
+		return nil, nil, nil
+	}
+
+	gameName := s.gameName(ctx, clip)
+
+	filters := make([]*ffmpeg.Filter, 0, len(s.overlayLayers))
+	textFiles := make([]string, 0, len(s.overlayLayers))
+
+	for i := range s.overlayLayers {
+		layer := &s.overlayLayers[i]
+
+		text, err := layer.renderText(clip, gameName)
+		if err != nil {
+			return nil, textFiles, fmt.Errorf("layer %d: %w", i, err)
+		}
+
+		textFile := fmt.Sprintf("%s.layer%d.txt", output, i)
+		if err = os.WriteFile(textFile, []byte(text), 0o644); err != nil {
+			return nil, textFiles, fmt.Errorf("write layer %d text: %w", i, err)
+		}
+		textFiles = append(textFiles, textFile)
+
+		filters = append(filters, layer.drawtextFilter(textFile, duration))
+	}
+
+	return filters, textFiles, nil
+}
+
+// gameName is cosmetic, the clip should not fail because of it
+func (s *Service) gameName(ctx context.Context, clip twitch.Clip) string {
+	gameName, err := s.client.GetGameName(ctx, clip.GameID)
+	if err != nil {
+		slog.Warn("Failed to get game name",
+			slog.String("game_id", clip.GameID),
+			slog.Any("error", err),
+		)
+	}
+
+	return gameName
+}
+
+// scaleFilters fit the video into the profile resolution keeping the aspect ratio
+func scaleFilters(profile config.EncodingProfile) []*ffmpeg.Filter {
+	return []*ffmpeg.Filter{
+		ffmpeg.NewFilter("scale").
+			Set("w", profile.Width).
+			Set("h", profile.Height).
+			Set("flags", "lanczos").
+			Set("force_original_aspect_ratio", "decrease"),
+		ffmpeg.NewFilter("pad").
+			Set("w", profile.Width).
+			Set("h", profile.Height).
+			Set("x", "(ow-iw)/2").
+			Set("y", "(oh-ih)/2").
+			Set("color", "black"),
+	}
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		_ = os.Remove(path)
+	}
+}
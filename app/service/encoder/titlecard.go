@@ -0,0 +1,189 @@
+package encoder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/pkg/config"
+	"k0pern1cus/pkg/ffmpeg"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// thumbnailClient has a timeout, so a stalled CDN response cannot hold a preload worker for good
+var thumbnailClient = &http.Client{Timeout: 30 * time.Second}
+
+// EncodeTitleCard renders the attribution card aired before the clip, the clip thumbnail is
+// blurred into the background and shown above the text, a black background is used without it
+func (s *Service) EncodeTitleCard(ctx context.Context, clip twitch.Clip, output string) error {
+	span := sentry.StartSpan(ctx, "encoder.encode_title_card")
+	defer span.Finish()
+
+	span.SetTag("clip_id", clip.ID)
+
+	cfg := &s.cfg.TitleCard
+	profile := s.cfg.ActiveProfile()
+	duration := strconv.FormatFloat(cfg.Duration, 'f', 3, 64)
+
+	var text strings.Builder
+	if err := s.titleCard.Execute(&text, overlayData(clip, s.gameName(ctx, clip))); err != nil {
+		return fmt.Errorf("execute template: %w", err)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-threads", "0",
+		"-y",
+	}
+
+	thumbnail := output + ".jpg"
+	defer os.Remove(thumbnail)
+
+	graph := ffmpeg.Graph{}
+	var video *ffmpeg.Chain
+
+	if err := downloadFile(ctx, clip.ThumbnailURL, thumbnail); err != nil {
+		slog.Warn("Failed to download clip thumbnail, using a black title card",
+			slog.String("clip_id", clip.ID),
+			slog.Any("error", err),
+		)
+
+		color := ffmpeg.NewFilter("color").
+			Set("c", "black").
+			Set("s", fmt.Sprintf("%dx%d", profile.Width, profile.Height)).
+			Set("r", profile.FPS)
+
+		args = append(args, "-f", "lavfi", "-t", duration, "-i", color.String())
+		video = ffmpeg.NewChain().From("0:v")
+	} else {
+		args = append(args, "-loop", "1", "-framerate", strconv.Itoa(profile.FPS), "-t", duration, "-i", thumbnail)
+
+		graph = append(graph,
+			ffmpeg.NewChain(ffmpeg.NewFilter("split").Set("outputs", 2)).From("0:v").To("bg", "fg"),
+			ffmpeg.NewChain(
+				ffmpeg.NewFilter("scale").
+					Set("w", profile.Width).
+					Set("h", profile.Height).
+					Set("force_original_aspect_ratio", "increase"),
+				ffmpeg.NewFilter("crop").Set("w", profile.Width).Set("h", profile.Height),
+				ffmpeg.NewFilter("boxblur").Set("luma_radius", 20).Set("luma_power", 2),
+				ffmpeg.NewFilter("eq").Set("brightness", -0.25),
+			).From("bg").To("bgo"),
+			ffmpeg.NewChain(
+				ffmpeg.NewFilter("scale").Set("w", -2).Set("h", profile.Height/3),
+			).From("fg").To("fgo"),
+		)
+
+		video = ffmpeg.NewChain(
+			ffmpeg.NewFilter("overlay").
+				Set("x", "(W-w)/2").
+				Set("y", profile.Height/8),
+		).From("bgo", "fgo")
+	}
+
+	args = append(args, "-f", "lavfi", "-i", silenceSource(profile).String())
+
+	lines := strings.Split(text.String(), "\n")
+	textFiles := make([]string, 0, len(lines))
+	defer func() {
+		removeFiles(textFiles)
+	}()
+
+	y := profile.Height * 9 / 16
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fontSize := profile.Height / 22
+		if i == 0 {
+			fontSize = profile.Height / 14
+		}
+
+		textFile := fmt.Sprintf("%s.line%d.txt", output, i)
+		if err := os.WriteFile(textFile, []byte(line), 0o644); err != nil {
+			return fmt.Errorf("write line %d: %w", i, err)
+		}
+		textFiles = append(textFiles, textFile)
+
+		video.Append(
+			ffmpeg.NewFilter("drawtext").
+				Set("textfile", textFile).
+				Set("expansion", "none").
+				Set("fontfile", cfg.FontFile).
+				Set("fontsize", fontSize).
+				Set("fontcolor", "white").
+				Set("shadowcolor", "black").
+				Set("shadowx", 2).
+				Set("shadowy", 2).
+				Set("x", "(w-text_w)/2").
+				Set("y", y),
+		)
+
+		y += fontSize * 3 / 2
+	}
+
+	if s.cfg.Transition.Mode == config.TransitionFade {
+		transitionDuration := min(s.cfg.Transition.Duration, cfg.Duration/2)
+
+		video.Append(
+			ffmpeg.NewFilter("fade").Set("t", "in").Set("st", 0.0).Set("d", transitionDuration),
+			ffmpeg.NewFilter("fade").Set("t", "out").Set("st", cfg.Duration-transitionDuration).Set("d", transitionDuration),
+		)
+	}
+
+	graph = append(graph, video.To("v"))
+
+	args = append(args,
+		"-filter_complex", graph.String(),
+		"-map", "[v]",
+		"-map", "1:a",
+		"-t", duration,
+	)
+	args = append(args, s.outputArgs(profile, output)...)
+
+	if err := ffmpeg.Run(ctx, clip.ID, args...); err != nil {
+		return fmt.Errorf("encode title card: %w", err)
+	}
+
+	return nil
+}
+
+func downloadFile(ctx context.Context, url, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	res, err := thumbnailClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err = io.Copy(file, res.Body); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package history
+
+import "time"
+
+// Outcome represents how a clip playback ended
+type Outcome string
+
+const (
+	OutcomePlayed  Outcome = "played"
+	OutcomeSkipped Outcome = "skipped"
+	OutcomeFailed  Outcome = "failed"
+	// OutcomeRejected means the clip did not pass the quality gate and never went on air
+	OutcomeRejected Outcome = "rejected"
+)
+
+// Entry represents a single line of the play history file
+type Entry struct {
+	ClipID   string    `json:"clip_id"`
+	PlayedAt time.Time `json:"played_at"`
+	Outcome  Outcome   `json:"outcome"`
+	Reason   string    `json:"reason,omitempty"`
+}
@@ -0,0 +1,193 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"k0pern1cus/pkg/config"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/samber/do"
+)
+
+type Service struct {
+	cfg *config.Config
+
+	m          sync.RWMutex
+	file       *os.File
+	lastPlayed map[string]time.Time
+	rejected   map[string]string
+}
+
+func New(di *do.Injector) (*Service, error) {
+	return &Service{
+		cfg:        do.MustInvoke[*config.Config](di),
+		lastPlayed: make(map[string]time.Time),
+		rejected:   make(map[string]string),
+	}, nil
+}
+
+func (s *Service) Init(ctx context.Context) error {
+	span := sentry.StartSpan(ctx, "history.init")
+	defer span.Finish()
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := s.load(); err != nil {
+		sentry.CaptureException(err)
+		return fmt.Errorf("load history: %w", err)
+	}
+
+	file, err := os.OpenFile(s.cfg.History.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		sentry.CaptureException(err)
+		return fmt.Errorf("open history file: %w", err)
+	}
+	s.file = file
+
+	slog.Info("Play history loaded",
+		slog.String("path", s.cfg.History.Path),
+		slog.Int("count", len(s.lastPlayed)),
+	)
+
+	return nil
+}
+
+func (s *Service) load() error {
+	file, err := os.Open(s.cfg.History.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a crash in the middle of a write leaves a broken last line
+			slog.Warn("Skipping malformed history entry",
+				slog.Any("error", err),
+			)
+			continue
+		}
+
+		s.apply(entry)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("scan: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) apply(entry Entry) {
+	switch entry.Outcome {
+	case OutcomeFailed:
+		return
+	case OutcomeRejected:
+		s.rejected[entry.ClipID] = entry.Reason
+		return
+	}
+
+	if entry.PlayedAt.After(s.lastPlayed[entry.ClipID]) {
+		s.lastPlayed[entry.ClipID] = entry.PlayedAt
+	}
+}
+
+// Record appends the clip playback outcome to the history file
+func (s *Service) Record(clipID string, outcome Outcome) error {
+	return s.write(Entry{
+		ClipID:   clipID,
+		PlayedAt: time.Now(),
+		Outcome:  outcome,
+	})
+}
+
+// Reject remembers that the clip failed the quality gate, so that it is not downloaded again
+func (s *Service) Reject(clipID, reason string) error {
+	return s.write(Entry{
+		ClipID:   clipID,
+		PlayedAt: time.Now(),
+		Outcome:  OutcomeRejected,
+		Reason:   reason,
+	})
+}
+
+func (s *Service) write(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal entry: %w", err)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.apply(entry)
+
+	if s.file == nil {
+		return fmt.Errorf("history is not initialized")
+	}
+
+	if _, err = s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write entry: %w", err)
+	}
+
+	return nil
+}
+
+// LastPlayed returns the last time the clip went on air
+func (s *Service) LastPlayed(clipID string) (time.Time, bool) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	playedAt, ok := s.lastPlayed[clipID]
+	return playedAt, ok
+}
+
+// Rejected returns the reason the clip was rejected by the quality gate
+func (s *Service) Rejected(clipID string) (string, bool) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	reason, ok := s.rejected[clipID]
+	return reason, ok
+}
+
+// InCooldown reports whether the clip was played too recently to be picked again
+func (s *Service) InCooldown(clipID string) bool {
+	if s.cfg.History.Cooldown <= 0 {
+		return false
+	}
+
+	playedAt, ok := s.LastPlayed(clipID)
+	if !ok {
+		return false
+	}
+
+	return time.Since(playedAt) < s.cfg.History.Cooldown
+}
+
+func (s *Service) Shutdown() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
@@ -0,0 +1,147 @@
+package history
+
+import (
+	"context"
+	"k0pern1cus/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/do"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestService loads the history file at path, it is created on first use
+func newTestService(t *testing.T, path string, cooldown time.Duration) *Service {
+	cfg := &config.Config{}
+	cfg.History.Path = path
+	cfg.History.Cooldown = cooldown
+
+	di := do.New()
+	do.ProvideValue(di, cfg)
+
+	s, err := New(di)
+	require.NoError(t, err)
+	require.NoError(t, s.Init(context.Background()))
+	t.Cleanup(func() {
+		_ = s.Shutdown()
+	})
+
+	return s
+}
+
+func TestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	s := newTestService(t, path, 0)
+	require.NoError(t, s.Record("played", OutcomePlayed))
+	require.NoError(t, s.Record("skipped", OutcomeSkipped))
+	require.NoError(t, s.Record("failed", OutcomeFailed))
+	require.NoError(t, s.Reject("rejected", "no video stream"))
+	require.NoError(t, s.Shutdown())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 4)
+
+	// a restart sees the same history, appending keeps the earlier entries
+	s = newTestService(t, path, 0)
+	require.NoError(t, s.Record("appended", OutcomePlayed))
+
+	tests := []struct {
+		clipID   string
+		played   bool
+		rejected string
+	}{
+		{"played", true, ""},
+		{"skipped", true, ""},
+		{"failed", false, ""},
+		{"rejected", false, "no video stream"},
+		{"appended", true, ""},
+		{"unknown", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.clipID, func(t *testing.T) {
+			_, played := s.LastPlayed(tt.clipID)
+			require.Equal(t, tt.played, played)
+
+			reason, rejected := s.Rejected(tt.clipID)
+			require.Equal(t, tt.rejected != "", rejected)
+			require.Equal(t, tt.rejected, reason)
+		})
+	}
+}
+
+func TestMalformedLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		played  []string
+	}{
+		{
+			name: "truncated last line",
+			content: `{"clip_id":"a","played_at":"2024-01-01T00:00:00Z","outcome":"played"}
+{"clip_id":"b","played_at":"2024-01-0`,
+			played: []string{"a"},
+		},
+		{
+			name: "garbage between entries",
+			content: `{"clip_id":"a","played_at":"2024-01-01T00:00:00Z","outcome":"played"}
+not json
+
+{"clip_id":"b","played_at":"2024-01-01T00:00:00Z","outcome":"skipped"}
+`,
+			played: []string{"a", "b"},
+		},
+		{
+			name:    "wrong field type",
+			content: `{"clip_id":"a","played_at":42,"outcome":"played"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history.jsonl")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+
+			s := newTestService(t, path, 0)
+			require.Len(t, s.lastPlayed, len(tt.played))
+			for _, clipID := range tt.played {
+				_, ok := s.LastPlayed(clipID)
+				require.True(t, ok, clipID)
+			}
+		})
+	}
+}
+
+func TestInCooldown(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		playedAt time.Time
+		outcome  Outcome
+		expected bool
+	}{
+		{"played recently", time.Hour, now.Add(-time.Minute), OutcomePlayed, true},
+		{"skipped recently", time.Hour, now.Add(-time.Minute), OutcomeSkipped, true},
+		{"played long ago", time.Hour, now.Add(-2 * time.Hour), OutcomePlayed, false},
+		{"failed recently", time.Hour, now.Add(-time.Minute), OutcomeFailed, false},
+		{"rejected recently", time.Hour, now.Add(-time.Minute), OutcomeRejected, false},
+		{"cooldown disabled", 0, now.Add(-time.Minute), OutcomePlayed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, filepath.Join(t.TempDir(), "history.jsonl"), tt.cooldown)
+			require.NoError(t, s.write(Entry{ClipID: "a", PlayedAt: tt.playedAt, Outcome: tt.outcome}))
+
+			require.Equal(t, tt.expected, s.InCooldown("a"))
+			require.False(t, s.InCooldown("unknown"))
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package streamer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// the outro has to be over before the stream deadline, the output buffers a bit
+var outroMargin = 10 * time.Second
+
+// encodeCardSegment encodes a card into <data_dir>/<name>.ts and measures it, returns nil on failure
+func (s *Service) encodeCardSegment(ctx context.Context, name string, encode func(output string) error) *segment {
+	output := filepath.Join(s.cfg.DataDir, name+".ts")
+
+	if err := encode(output); err != nil {
+		sentry.CaptureException(err)
+		slog.Error("Failed to encode card",
+			slog.String("card", name),
+			slog.Any("error", err),
+		)
+		return nil
+	}
+
+	duration, err := s.encoder.ProbeDuration(ctx, output)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("Failed to measure card duration",
+			slog.String("card", name),
+			slog.Any("error", err),
+		)
+		_ = os.Remove(output)
+		return nil
+	}
+
+	return &segment{
+		path:     output,
+		duration: duration,
+		gap:      segmentGap,
+		boundary: true,
+	}
+}
+
+// bumperSegment returns the next bumper in rotation, each one is encoded once on first use
+func (s *Service) bumperSegment(ctx context.Context) *segment {
+	items := s.cfg.Bumpers.Items
+	if len(items) == 0 {
+		return nil
+	}
+
+	index := s.bumperPos % len(items)
+	s.bumperPos++
+
+	bumper, ok := s.bumpers[index]
+	if !ok {
+		bumper = s.encodeCardSegment(ctx, fmt.Sprintf("bumper_%d", index), func(output string) error {
+			return s.encoder.EncodeCard(ctx, items[index], output)
+		})
+		// a broken bumper is not retried
+		s.bumpers[index] = bumper
+	}
+
+	if bumper == nil {
+		return nil
+	}
+
+	result := *bumper
+	return &result
+}
+
+// bumperDue reports whether enough clips or airtime went by since the last bumper
+func (s *Service) bumperDue(clipCount int, airtime time.Duration) bool {
+	if len(s.cfg.Bumpers.Items) == 0 {
+		return false
+	}
+
+	if s.cfg.Bumpers.EveryClips > 0 && clipCount >= s.cfg.Bumpers.EveryClips {
+		return true
+	}
+
+	return s.cfg.Bumpers.Every > 0 && airtime >= s.cfg.Bumpers.Every
+}
+
+// countdownSegment renders the starting soon countdown aired before the first clip
+func (s *Service) countdownSegment(ctx context.Context) *segment {
+	if !s.cfg.Countdown.Enabled {
+		return nil
+	}
+
+	slog.Info("Encoding the countdown...")
+
+	countdown := s.encodeCardSegment(ctx, "countdown", func(output string) error {
+		return s.encoder.EncodeCountdown(ctx, s.cfg.Countdown.Card, output)
+	})
+	if countdown == nil {
+		return nil
+	}
+
+	countdown.boundary = false
+	countdown.release = func() {
+		_ = os.Remove(countdown.path)
+	}
+
+	return countdown
+}
+
+// outroSegment returns the outro encoded with the active profile, it is encoded once on first use
+func (s *Service) outroSegment(ctx context.Context) *segment {
+	s.outroOnce.Do(func() {
+		s.outro = s.encodeCardSegment(ctx, "outro", func(output string) error {
+			return s.encoder.EncodeCard(ctx, s.cfg.Outro.Card, output)
+		})
+	})
+
+	return s.outro
+}
+
+// outroDue returns the outro if the segment would not leave enough time for it before the stream deadline
+func (s *Service) outroDue(ctx context.Context, seg *segment) *segment {
+	if !s.cfg.Outro.Enabled {
+		return nil
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+
+	outro := s.outroSegment(ctx)
+	if outro == nil {
+		return nil
+	}
+
+	if time.Until(deadline) > seg.duration+seg.gap+outro.duration+outroMargin {
+		return nil
+	}
+
+	return outro
+}
@@ -0,0 +1,119 @@
+package streamer
+
+import (
+	"context"
+	"errors"
+	"k0pern1cus/app/client/twitch"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// ErrPauseUnavailable is returned by Pause when the slate is disabled, the output
+// needs something to air while the rotation is held or the ingest drops the stream
+var ErrPauseUnavailable = errors.New("pause needs the slate enabled")
+
+func (s *Service) enqueue(clip clipHandle) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.queue = append(s.queue, clip)
+}
+
+func (s *Service) dequeue(clip clipHandle) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.queue = slices.DeleteFunc(s.queue, func(other clipHandle) bool {
+		return other == clip
+	})
+}
+
+func (s *Service) setCurrent(clip clipHandle, skip context.CancelFunc) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.current = clip
+	s.currentStart = time.Now()
+	s.skipCurrent = skip
+}
+
+// NowPlaying returns the clip that is currently on air and the time it started
+func (s *Service) NowPlaying() (twitch.Clip, time.Time, bool) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.current == nil {
+		return twitch.Clip{}, time.Time{}, false
+	}
+
+	return s.current.Clip(), s.currentStart, true
+}
+
+// Queue returns the preloaded clips in the order they are going to be played
+func (s *Service) Queue() []twitch.Clip {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	queue := make([]twitch.Clip, 0, len(s.queue))
+	for _, clip := range s.queue {
+		queue = append(queue, clip.Clip())
+	}
+
+	return queue
+}
+
+// Skip stops the current clip and moves on to the next one
+func (s *Service) Skip() bool {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.skipCurrent == nil {
+		return false
+	}
+
+	s.skipCurrent()
+	return true
+}
+
+// Pause holds the rotation once the current clip is over, the slate is aired meanwhile
+func (s *Service) Pause() error {
+	if !s.cfg.Slate.Enabled {
+		return ErrPauseUnavailable
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.paused {
+		return nil
+	}
+
+	s.paused = true
+	s.resumeChan = make(chan struct{})
+
+	slog.Info("Stream paused")
+
+	return nil
+}
+
+func (s *Service) Resume() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.paused {
+		return
+	}
+
+	s.paused = false
+	close(s.resumeChan)
+
+	slog.Info("Stream resumed")
+}
+
+func (s *Service) IsPaused() bool {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.paused
+}
@@ -0,0 +1,23 @@
+package streamer
+
+import (
+	"k0pern1cus/pkg/config"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPause(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+
+	require.ErrorIs(t, s.Pause(), ErrPauseUnavailable)
+	require.False(t, s.IsPaused())
+
+	s.cfg.Slate.Enabled = true
+
+	require.NoError(t, s.Pause())
+	require.True(t, s.IsPaused())
+
+	s.Resume()
+	require.False(t, s.IsPaused())
+}
@@ -0,0 +1,178 @@
+package streamer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"k0pern1cus/pkg/config"
+	"k0pern1cus/pkg/ffmpeg"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+var restartMinBackoff = time.Second
+var restartMaxBackoff = time.Minute
+var stableOutputDuration = 5 * time.Minute
+
+var teeEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`|`, `\|`,
+	`'`, `\'`,
+	`[`, `\[`,
+	`]`, `\]`,
+)
+
+// fifoRecovery makes the fifo of a destination reconnect on its own after it drops, packets are
+// dropped meanwhile instead of stalling the remaining destinations
+var fifoRecovery = []string{
+	"attempt_recovery=1",
+	"recover_any_error=1",
+	"recovery_wait_time=2",
+	"drop_pkts_on_overflow=1",
+	"restart_with_keyframe=1",
+}
+
+// teeOutput builds the tee muxer target that fans a single encode out to every destination,
+// onfail=ignore keeps the remaining destinations alive when one of them goes down
+func teeOutput(destinations []config.Destination) string {
+	slaves := make([]string, 0, len(destinations))
+
+	// the tee muxer unescapes the slave list and then the slave options, so the nested separators are escaped twice
+	fifoOptions := "fifo_options=" + strings.Join(fifoRecovery, `\\\:`)
+
+	for _, destination := range destinations {
+		options := []string{
+			"f=" + destination.ResolveFormat(),
+			"onfail=ignore",
+			fifoOptions,
+		}
+
+		if destination.ResolveFormat() == "flv" {
+			options = append(options, "flvflags=no_duration_filesize")
+		}
+
+		slaves = append(slaves, fmt.Sprintf("[%s]%s", strings.Join(options, ":"), teeEscaper.Replace(destination.URL)))
+	}
+
+	return strings.Join(slaves, "|")
+}
+
+// outputProcess is the long-living ffmpeg that pushes the mpegts fed to its stdin to the destinations
+type outputProcess struct {
+	stdin     io.WriteCloser
+	done      chan struct{}
+	err       error
+	startedAt time.Time
+}
+
+func (o *outputProcess) Dead() bool {
+	select {
+	case <-o.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *outputProcess) Close() {
+	_ = o.stdin.Close()
+}
+
+func (s *Service) startStreamerProcess(ctx context.Context) (*outputProcess, error) {
+	localHub := sentry.CurrentHub().Clone()
+
+	span := sentry.StartSpan(ctx, "streamer.streamer_process")
+	defer span.Finish()
+	defer sentry.Recover()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-threads", "0",
+		"-re",
+		"-f", "mpegts",
+		"-i", "pipe:0",
+		"-map", "0",
+		"-c:v", "copy",
+		"-c:a", "copy",
+		"-fflags", "+genpts",
+		"-copyts",
+		"-max_delay", "1000000",
+		"-rtbufsize", "512M",
+		"-bufsize", fmt.Sprintf("%dk", s.cfg.ActiveProfile().VideoBitrate),
+		"-f", "tee",
+		"-use_fifo", "1",
+		teeOutput(s.cfg.Stream.Destinations),
+	)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		localHub.CaptureException(err)
+		return nil, fmt.Errorf("create stdin pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		localHub.CaptureException(err)
+		return nil, fmt.Errorf("create stderr pipe: %w", err)
+	}
+
+	if err = cmd.Start(); err != nil {
+		localHub.CaptureException(err)
+		return nil, fmt.Errorf("start ffmpeg: %w", err)
+	}
+
+	go ffmpeg.MonitorOutput(stderr, "main")
+
+	out := &outputProcess{
+		stdin:     stdin,
+		done:      make(chan struct{}),
+		startedAt: time.Now(),
+	}
+
+	go func() {
+		defer close(out.done)
+		out.err = cmd.Wait()
+	}()
+
+	return out, nil
+}
+
+// restartStreamerProcess replaces the dead output process, backing off exponentially between attempts
+func (s *Service) restartStreamerProcess(ctx context.Context, dead *outputProcess, attempt int) (*outputProcess, error) {
+	dead.Close()
+
+	cause := dead.err
+
+	for {
+		backoff := min(restartMinBackoff<<min(attempt, 16), restartMaxBackoff)
+
+		sentry.CaptureMessage(fmt.Sprintf("Streamer process died, restart attempt %d: %v", attempt+1, cause))
+		slog.Error("Streamer process died, restarting",
+			slog.Any("error", cause),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", backoff),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		out, err := s.startStreamerProcess(ctx)
+		if err == nil {
+			slog.Info("Streamer process restarted",
+				slog.Int("attempt", attempt+1),
+			)
+			return out, nil
+		}
+
+		cause = err
+		attempt++
+	}
+}
@@ -0,0 +1,23 @@
+package streamer
+
+import (
+	"k0pern1cus/pkg/config"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeeOutput(t *testing.T) {
+	output := teeOutput([]config.Destination{
+		{URL: "rtmp://live.twitch.tv/app/key"},
+		{URL: "srt://example.com:9000?streamid=a|b", Format: "mpegts"},
+	})
+
+	fifo := `fifo_options=attempt_recovery=1\\\:recover_any_error=1\\\:recovery_wait_time=2\\\:drop_pkts_on_overflow=1\\\:restart_with_keyframe=1`
+
+	require.Equal(t,
+		"[f=flv:onfail=ignore:"+fifo+":flvflags=no_duration_filesize]rtmp://live.twitch.tv/app/key|"+
+			"[f=mpegts:onfail=ignore:"+fifo+`]srt://example.com:9000?streamid=a\|b`,
+		output,
+	)
+}
@@ -0,0 +1,287 @@
+package streamer
+
+import (
+	"context"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/pkg/config"
+	"time"
+)
+
+// clipHandle is the part of a prepared clip the streamer airs, *clips.ClipHandle outside of the tests
+type clipHandle interface {
+	Clip() twitch.Clip
+	GetPreparedFile() (string, bool)
+	GetPreciseDuration() time.Duration
+	GetCrossfadeFiles() (string, string, bool)
+	GetTitleCardFile() (string, time.Duration, bool)
+	Release()
+}
+
+// planner decides which segments are aired for each clip: title cards, transitions, stingers, clip bodies,
+// tails and bumpers. It runs one clip ahead of the output, so transitions that need the next clip are encoded
+// while the current one is on air. Encoding is left to the callbacks, so the planning can be tested on its own
+type planner struct {
+	mode         string
+	fadeDuration time.Duration
+	out          chan<- *segment
+
+	// nextClip blocks until the next prepared clip, false once there are none left
+	nextClip func(ctx context.Context) (clipHandle, bool)
+	// dropClip takes a clip that failed to prepare out of the rotation, the planner releases it
+	dropClip func(clip clipHandle)
+	// transition blends the tail of prev into the head of clip, nil falls back to a cut
+	transition func(ctx context.Context, prev, clip clipHandle, tail, head string) *segment
+	stinger    func(ctx context.Context) *segment
+	bumper     func(ctx context.Context) *segment
+	bumperDue  func(clipCount int, airtime time.Duration) bool
+
+	// pendingRelease releases the previous clip once the segment that follows it is aired,
+	// its tail is blended into whatever comes next
+	pendingRelease func()
+}
+
+func (p *planner) run(ctx context.Context) {
+	defer close(p.out)
+	defer func() {
+		if p.pendingRelease != nil {
+			p.pendingRelease()
+		}
+	}()
+
+	var prev clipHandle
+
+	// clips and airtime since the last bumper
+	var clipCount int
+	var airtime time.Duration
+
+	for {
+		if prev != nil && p.bumperDue(clipCount, airtime) {
+			if !p.emitBumper(ctx, prev) {
+				return
+			}
+
+			clipCount = 0
+			airtime = 0
+			// the clip after the bumper starts on its own
+			prev = nil
+		}
+
+		clip, ok := p.nextClip(ctx)
+		if !ok {
+			// the last clip ends on its own, its files are released once the tail is aired
+			if prev != nil && ctx.Err() == nil {
+				p.emitTail(ctx, prev)
+			}
+			return
+		}
+
+		if _, ok = clip.GetPreparedFile(); !ok {
+			// the previous clip stays pending, the next one blends into it
+			p.dropClip(clip)
+			clip.Release()
+			continue
+		}
+
+		if !p.emitClip(ctx, prev, clip) {
+			return
+		}
+
+		clipCount++
+		airtime += clip.GetPreciseDuration()
+		prev = clip
+	}
+}
+
+// emitClip emits the lead-in segments and the body of a prepared clip, returns false if the context is done
+func (p *planner) emitClip(ctx context.Context, prev, clip clipHandle) bool {
+	filePath, _ := clip.GetPreparedFile()
+
+	body := &segment{
+		path:     filePath,
+		duration: clip.GetPreciseDuration(),
+		gap:      segmentGap,
+		clip:     clip,
+		main:     true,
+		boundary: true,
+	}
+
+	// lead-in segments aired before the clip body
+	var leads []*segment
+	title := titleCardSegment(clip)
+
+	switch p.mode {
+	case config.TransitionCrossfade:
+		if title != nil && prev != nil {
+			// the title card breaks the transition chain, the previous clip ends on its own
+			if !p.emitTail(ctx, prev) {
+				clip.Release()
+				return false
+			}
+			prev = nil
+		}
+
+		if title != nil {
+			leads = append(leads, title)
+		}
+
+		lead, blended := p.crossfadeSegment(ctx, prev, clip)
+		if prev != nil && !blended {
+			// there is nothing to blend the previous tail into, the previous clip ends on its own
+			if !p.emitTail(ctx, prev) {
+				clip.Release()
+				return false
+			}
+		}
+		if lead != nil {
+			leads = append(leads, lead)
+		}
+	case config.TransitionStinger:
+		if prev != nil {
+			if lead := p.stinger(ctx); lead != nil {
+				leads = append(leads, lead)
+			}
+		}
+
+		if title != nil {
+			leads = append(leads, title)
+		}
+	default:
+		if title != nil {
+			leads = append(leads, title)
+		}
+
+		body.gap = artificialOffset
+	}
+
+	// pause takes effect before the first segment of the clip only
+	for i, lead := range leads {
+		lead.boundary = i == 0
+		body.boundary = false
+
+		if !p.emit(ctx, lead) {
+			clip.Release()
+			return false
+		}
+	}
+
+	// a clip with a tail keeps its files until the tail is blended into the next segment
+	if _, _, ok := clip.GetCrossfadeFiles(); ok && p.mode == config.TransitionCrossfade {
+		if !p.emit(ctx, body) {
+			clip.Release()
+			return false
+		}
+		p.pendingRelease = clip.Release
+
+		return true
+	}
+
+	body.release = clip.Release
+
+	return p.emit(ctx, body)
+}
+
+// emit hands the segment to the output, the pending clip is released along with it
+func (p *planner) emit(ctx context.Context, seg *segment) bool {
+	if p.pendingRelease != nil {
+		release := seg.release
+		previous := p.pendingRelease
+		seg.release = func() {
+			previous()
+			if release != nil {
+				release()
+			}
+		}
+		p.pendingRelease = nil
+	}
+
+	select {
+	case <-ctx.Done():
+		seg.Release()
+		return false
+	case p.out <- seg:
+		return true
+	}
+}
+
+// emitBumper airs the tail left over from the previous clip in crossfade mode and the next bumper
+func (p *planner) emitBumper(ctx context.Context, prev clipHandle) bool {
+	if !p.emitTail(ctx, prev) {
+		return false
+	}
+
+	bumper := p.bumper(ctx)
+	if bumper == nil {
+		return true
+	}
+
+	return p.emit(ctx, bumper)
+}
+
+// emitTail airs the clip tail on its own when there is no next clip to blend it into
+func (p *planner) emitTail(ctx context.Context, clip clipHandle) bool {
+	if p.mode != config.TransitionCrossfade {
+		return true
+	}
+
+	tail := tailSegment(clip, p.fadeDuration)
+	if tail == nil {
+		return true
+	}
+
+	return p.emit(ctx, tail)
+}
+
+// crossfadeSegment blends the previous clip tail into the clip head and reports whether it did, the head is aired
+// alone for the first clip or if the transition failed, nil is returned for clips too short to crossfade
+func (p *planner) crossfadeSegment(ctx context.Context, prev, clip clipHandle) (*segment, bool) {
+	head, _, ok := clip.GetCrossfadeFiles()
+	if !ok {
+		return nil, false
+	}
+
+	if prev != nil {
+		if _, tail, ok := prev.GetCrossfadeFiles(); ok {
+			if transition := p.transition(ctx, prev, clip, tail, head); transition != nil {
+				return transition, true
+			}
+		}
+	}
+
+	return &segment{
+		path:     head,
+		duration: p.fadeDuration,
+		gap:      segmentGap,
+		clip:     clip,
+		boundary: true,
+	}, false
+}
+
+// tailSegment returns the clip tail, nil for clips too short to crossfade
+func tailSegment(clip clipHandle, duration time.Duration) *segment {
+	_, tail, ok := clip.GetCrossfadeFiles()
+	if !ok {
+		return nil
+	}
+
+	return &segment{
+		path:     tail,
+		duration: duration,
+		gap:      segmentGap,
+		clip:     clip,
+	}
+}
+
+// titleCardSegment returns the clip title card prepared during preload
+func titleCardSegment(clip clipHandle) *segment {
+	path, duration, ok := clip.GetTitleCardFile()
+	if !ok {
+		return nil
+	}
+
+	return &segment{
+		path:     path,
+		duration: duration,
+		gap:      segmentGap,
+		clip:     clip,
+	}
+}
@@ -0,0 +1,285 @@
+package streamer
+
+import (
+	"context"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/pkg/config"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// eventLog records what was aired and released, in order
+type eventLog struct {
+	m      sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) list() []string {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return append([]string(nil), l.events...)
+}
+
+type fakeHandle struct {
+	id        string
+	failed    bool
+	crossfade bool
+	title     bool
+	log       *eventLog
+}
+
+func (h *fakeHandle) Clip() twitch.Clip {
+	return twitch.Clip{ID: h.id}
+}
+
+func (h *fakeHandle) GetPreparedFile() (string, bool) {
+	return h.id + ".ts", !h.failed
+}
+
+func (h *fakeHandle) GetPreciseDuration() time.Duration {
+	return 30 * time.Second
+}
+
+func (h *fakeHandle) GetCrossfadeFiles() (string, string, bool) {
+	return h.id + "_head.ts", h.id + "_tail.ts", h.crossfade && !h.failed
+}
+
+func (h *fakeHandle) GetTitleCardFile() (string, time.Duration, bool) {
+	return h.id + "_title.ts", 5 * time.Second, h.title && !h.failed
+}
+
+func (h *fakeHandle) Release() {
+	h.log.add("release " + h.id)
+}
+
+func newTestPlanner(mode string, log *eventLog, out chan<- *segment, handles ...*fakeHandle) *planner {
+	var next int
+
+	return &planner{
+		mode:         mode,
+		fadeDuration: time.Second,
+		out:          out,
+		nextClip: func(ctx context.Context) (clipHandle, bool) {
+			if next == len(handles) {
+				return nil, false
+			}
+
+			next++
+			return handles[next-1], true
+		},
+		dropClip: func(clip clipHandle) {
+			log.add("drop " + clip.Clip().ID)
+		},
+		transition: func(ctx context.Context, prev, clip clipHandle, tail, head string) *segment {
+			path := "transition_" + prev.Clip().ID + "_" + clip.Clip().ID + ".ts"
+
+			return &segment{
+				path:     path,
+				duration: time.Second,
+				clip:     clip,
+				release: func() {
+					log.add("release " + path)
+				},
+			}
+		},
+		stinger: func(ctx context.Context) *segment {
+			return &segment{path: "stinger.ts", duration: time.Second}
+		},
+		bumper: func(ctx context.Context) *segment {
+			return &segment{path: "bumper.ts", duration: 5 * time.Second}
+		},
+		bumperDue: func(clipCount int, airtime time.Duration) bool {
+			return false
+		},
+	}
+}
+
+// airAll plays the role of the output, every segment is aired and released before the next one is taken
+func airAll(p *planner, segments <-chan *segment, log *eventLog) {
+	go p.run(context.Background())
+
+	for seg := range segments {
+		event := "air " + seg.path
+		if seg.boundary {
+			event += " (boundary)"
+		}
+		log.add(event)
+
+		seg.Release()
+	}
+}
+
+func TestPlannerCrossfade(t *testing.T) {
+	log := &eventLog{}
+	segments := make(chan *segment)
+
+	p := newTestPlanner(config.TransitionCrossfade, log, segments,
+		&fakeHandle{id: "a", crossfade: true, log: log},
+		&fakeHandle{id: "b", crossfade: true, log: log},
+		&fakeHandle{id: "short", log: log},
+		&fakeHandle{id: "c", crossfade: true, log: log},
+	)
+	airAll(p, segments, log)
+
+	require.Equal(t, []string{
+		"air a_head.ts (boundary)",
+		"air a.ts",
+		"air transition_a_b.ts (boundary)",
+		"release a",
+		"release transition_a_b.ts",
+		"air b.ts",
+		// a clip too short to crossfade is cut to once the previous tail is aired
+		"air b_tail.ts",
+		"release b",
+		"air short.ts (boundary)",
+		"release short",
+		"air c_head.ts (boundary)",
+		"air c.ts",
+		// the last clip ends on its own
+		"air c_tail.ts",
+		"release c",
+	}, log.list())
+}
+
+func TestPlannerTransitionFailed(t *testing.T) {
+	log := &eventLog{}
+	segments := make(chan *segment)
+
+	p := newTestPlanner(config.TransitionCrossfade, log, segments,
+		&fakeHandle{id: "a", crossfade: true, log: log},
+		&fakeHandle{id: "b", crossfade: true, log: log},
+	)
+	p.transition = func(ctx context.Context, prev, clip clipHandle, tail, head string) *segment {
+		return nil
+	}
+	airAll(p, segments, log)
+
+	require.Equal(t, []string{
+		"air a_head.ts (boundary)",
+		"air a.ts",
+		// the tail and the head are aired on their own, with a cut between them
+		"air a_tail.ts",
+		"release a",
+		"air b_head.ts (boundary)",
+		"air b.ts",
+		"air b_tail.ts",
+		"release b",
+	}, log.list())
+}
+
+func TestPlannerTitleCardBreaksCrossfade(t *testing.T) {
+	log := &eventLog{}
+	segments := make(chan *segment)
+
+	p := newTestPlanner(config.TransitionCrossfade, log, segments,
+		&fakeHandle{id: "a", crossfade: true, log: log},
+		&fakeHandle{id: "b", crossfade: true, title: true, log: log},
+	)
+	airAll(p, segments, log)
+
+	require.Equal(t, []string{
+		"air a_head.ts (boundary)",
+		"air a.ts",
+		"air a_tail.ts",
+		"release a",
+		// pause takes effect before the title card, not between it and the clip
+		"air b_title.ts (boundary)",
+		"air b_head.ts",
+		"air b.ts",
+		"air b_tail.ts",
+		"release b",
+	}, log.list())
+}
+
+func TestPlannerTailBeforeBumper(t *testing.T) {
+	log := &eventLog{}
+	segments := make(chan *segment)
+
+	p := newTestPlanner(config.TransitionCrossfade, log, segments,
+		&fakeHandle{id: "a", crossfade: true, log: log},
+		&fakeHandle{id: "b", crossfade: true, log: log},
+	)
+	p.bumperDue = func(clipCount int, airtime time.Duration) bool {
+		return clipCount >= 1
+	}
+	airAll(p, segments, log)
+
+	require.Equal(t, []string{
+		"air a_head.ts (boundary)",
+		"air a.ts",
+		"air a_tail.ts",
+		"release a",
+		"air bumper.ts",
+		"air b_head.ts (boundary)",
+		"air b.ts",
+		"air b_tail.ts",
+		"release b",
+		"air bumper.ts",
+	}, log.list())
+}
+
+func TestPlannerStinger(t *testing.T) {
+	log := &eventLog{}
+	segments := make(chan *segment)
+
+	p := newTestPlanner(config.TransitionStinger, log, segments,
+		&fakeHandle{id: "failed", failed: true, log: log},
+		&fakeHandle{id: "a", log: log},
+		&fakeHandle{id: "b", title: true, log: log},
+	)
+	airAll(p, segments, log)
+
+	require.Equal(t, []string{
+		"drop failed",
+		"release failed",
+		"air a.ts (boundary)",
+		"release a",
+		"air stinger.ts (boundary)",
+		"air b_title.ts",
+		"air b.ts",
+		"release b",
+	}, log.list())
+}
+
+func TestPlannerReleaseOnCancel(t *testing.T) {
+	log := &eventLog{}
+	segments := make(chan *segment)
+
+	p := newTestPlanner(config.TransitionCrossfade, log, segments,
+		&fakeHandle{id: "a", crossfade: true, log: log},
+		&fakeHandle{id: "b", crossfade: true, log: log},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		p.run(ctx)
+	}()
+
+	// the output stops after the body of the first clip
+	for range 2 {
+		(<-segments).Release()
+	}
+	cancel()
+	<-done
+
+	// the transition that was never aired is released along with both clips
+	require.ElementsMatch(t, []string{
+		"release a",
+		"release transition_a_b.ts",
+		"release b",
+	}, log.list())
+}
@@ -0,0 +1,317 @@
+package streamer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"k0pern1cus/app/client/twitch"
+	"k0pern1cus/pkg/config"
+	"k0pern1cus/pkg/ffmpeg"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// chapter is a clip of the compilation and the time it starts at, lead-ins included
+type chapter struct {
+	start time.Duration
+	clip  twitch.Clip
+}
+
+// Render writes a compilation of clips to the render output instead of going live,
+// with a chapters file and a credits list next to it. Clips are selected, prepared and
+// sequenced exactly like on stream, so overlays, title cards, transitions and bumpers are kept
+func (s *Service) Render(ctx context.Context) error {
+	span := sentry.StartSpan(ctx, "streamer.render")
+	defer span.Finish()
+	defer sentry.Recover()
+
+	output := s.cfg.Render.Output
+	s.rendering = true
+
+	slog.Info("Rendering the compilation...",
+		slog.String("output", output),
+	)
+
+	// stops the preload and the sequencer once the compilation is long enough
+	renderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.startPreloadWorkers(renderCtx)
+
+	out, err := s.startRenderProcess(ctx, output)
+	if err != nil {
+		sentry.CaptureException(err)
+		return fmt.Errorf("start render process: %w", err)
+	}
+	defer func() {
+		out.Close()
+	}()
+
+	segments := make(chan *segment)
+	go s.sequence(renderCtx, segments)
+
+	var currentOffset time.Duration
+	var chapters []chapter
+	var chapterClip clipHandle
+	var chapterStart time.Duration
+	var last clipHandle
+
+	for !s.renderDone(len(chapters), currentOffset) {
+		seg, ok := s.getNextSegment(renderCtx, segments)
+		if !ok {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			slog.Warn("Clip pool exhausted, the compilation is shorter than requested",
+				slog.Int("clips", len(chapters)),
+			)
+			// the sequencer has aired the tail of the last clip already
+			last = nil
+			break
+		}
+
+		// the chapter starts with the first lead-in of the clip
+		if seg.clip != nil && seg.clip != chapterClip {
+			chapterClip = seg.clip
+			chapterStart = currentOffset
+		}
+
+		if seg.clip != nil {
+			s.dequeue(seg.clip)
+
+			if seg.main {
+				slog.Info("Rendering video",
+					slog.String("clip_url", seg.clip.Clip().URL),
+				)
+			}
+		}
+
+		currentOffset, err = s.renderSegment(ctx, seg, out.stdin, currentOffset)
+		if err != nil {
+			seg.Release()
+			return fmt.Errorf("render segment %s: %w", seg.name(), err)
+		}
+
+		if seg.main {
+			chapters = append(chapters, chapter{
+				start: chapterStart,
+				clip:  seg.clip.Clip(),
+			})
+			last = seg.clip
+		}
+
+		seg.Release()
+	}
+
+	// the sequencer keeps the last clip files until it is cancelled, so its tail is aired first
+	if last != nil && s.cfg.Transition.Mode == config.TransitionCrossfade {
+		if tail := tailSegment(last, s.transitionDuration()); tail != nil {
+			if currentOffset, err = s.renderSegment(ctx, tail, out.stdin, currentOffset); err != nil {
+				return fmt.Errorf("render tail: %w", err)
+			}
+		}
+	}
+
+	cancel()
+	s.drainRender(segments)
+
+	if s.cfg.Outro.Enabled && len(chapters) > 0 {
+		if outro := s.outroSegment(ctx); outro != nil {
+			if currentOffset, err = s.renderSegment(ctx, outro, out.stdin, currentOffset); err != nil {
+				return fmt.Errorf("render outro: %w", err)
+			}
+		}
+	}
+
+	out.Close()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-out.done:
+	}
+
+	if out.err != nil {
+		return fmt.Errorf("render process: %w", out.err)
+	}
+
+	if len(chapters) == 0 {
+		_ = os.Remove(output)
+		return fmt.Errorf("no clips available")
+	}
+
+	if err = s.writeRenderSidecars(output, chapters); err != nil {
+		return fmt.Errorf("write sidecar files: %w", err)
+	}
+
+	slog.Info("Compilation rendered",
+		slog.String("output", output),
+		slog.Int("clips", len(chapters)),
+		slog.Duration("duration", currentOffset),
+	)
+
+	return nil
+}
+
+// renderSegment appends the segment right after the previous one, the gaps between segments that
+// let the live output catch up would be frozen frames and audio dropouts in the file
+func (s *Service) renderSegment(ctx context.Context, seg *segment, stdin io.WriteCloser, offset time.Duration) (time.Duration, error) {
+	rendered := *seg
+	rendered.gap = 0
+
+	offset, _, err := s.streamSegment(ctx, &rendered, stdin, offset)
+
+	return offset, err
+}
+
+// renderDone reports whether the compilation reached the requested clip count or duration
+func (s *Service) renderDone(clipCount int, duration time.Duration) bool {
+	if s.cfg.Render.Clips > 0 && clipCount >= s.cfg.Render.Clips {
+		return true
+	}
+
+	return s.cfg.Render.Duration > 0 && duration >= s.cfg.Render.Duration
+}
+
+// drainRender releases the segments and clips that were prepared ahead but did not make it into the compilation
+func (s *Service) drainRender(segments <-chan *segment) {
+	for seg := range segments {
+		seg.Release()
+	}
+
+	for clip := range s.preloadChan {
+		clip.Release()
+	}
+}
+
+// startRenderProcess starts the ffmpeg that remuxes the mpegts fed to its stdin into the output file
+func (s *Service) startRenderProcess(ctx context.Context, output string) (*outputProcess, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-f", "mpegts",
+		"-i", "pipe:0",
+		"-map", "0",
+		"-c", "copy",
+		"-movflags", "+faststart",
+		"-y",
+		output,
+	)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("create stdin pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("create stderr pipe: %w", err)
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start ffmpeg: %w", err)
+	}
+
+	go ffmpeg.MonitorOutput(stderr, "render")
+
+	out := &outputProcess{
+		stdin:     stdin,
+		done:      make(chan struct{}),
+		startedAt: time.Now(),
+	}
+
+	go func() {
+		defer close(out.done)
+		out.err = cmd.Wait()
+	}()
+
+	return out, nil
+}
+
+// writeRenderSidecars writes <name>.chapters.txt and <name>.credits.txt next to the compilation
+func (s *Service) writeRenderSidecars(output string, chapters []chapter) error {
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+
+	if err := os.WriteFile(base+".chapters.txt", []byte(formatChapters(chapters)), 0o644); err != nil {
+		return fmt.Errorf("write chapters: %w", err)
+	}
+
+	if err := os.WriteFile(base+".credits.txt", []byte(formatCredits(chapters)), 0o644); err != nil {
+		return fmt.Errorf("write credits: %w", err)
+	}
+
+	return nil
+}
+
+// formatChapters lists the clips in the YouTube description timestamp format
+func formatChapters(chapters []chapter) string {
+	var b strings.Builder
+
+	for i, c := range chapters {
+		start := c.start
+		// YouTube only picks the chapters up if the first one starts at zero
+		if i == 0 {
+			start = 0
+		}
+
+		fmt.Fprintf(&b, "%s %s - %s\n",
+			formatTimestamp(start),
+			strings.Join(strings.Fields(c.clip.Title), " "),
+			c.clip.BroadcasterName,
+		)
+	}
+
+	return b.String()
+}
+
+// formatTimestamp formats the duration as m:ss, or h:mm:ss past the first hour
+func formatTimestamp(d time.Duration) string {
+	total := int(d.Seconds())
+	hours, minutes, seconds := total/3600, total%3600/60, total%60
+
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+	}
+
+	return fmt.Sprintf("%d:%02d", minutes, seconds)
+}
+
+// formatCredits lists the broadcasters and the clippers of the compilation in the order they appear
+func formatCredits(chapters []chapter) string {
+	var broadcasters, clippers []string
+	seenBroadcasters := make(map[string]struct{})
+	seenClippers := make(map[string]struct{})
+
+	for _, c := range chapters {
+		if _, ok := seenBroadcasters[c.clip.BroadcasterName]; !ok {
+			seenBroadcasters[c.clip.BroadcasterName] = struct{}{}
+			broadcasters = append(broadcasters, c.clip.BroadcasterName)
+		}
+
+		if _, ok := seenClippers[c.clip.CreatorName]; !ok {
+			seenClippers[c.clip.CreatorName] = struct{}{}
+			clippers = append(clippers, c.clip.CreatorName)
+		}
+	}
+
+	var b strings.Builder
+
+	b.WriteString("Broadcasters:\n")
+	for _, name := range broadcasters {
+		fmt.Fprintf(&b, "%s\n", name)
+	}
+
+	b.WriteString("\nClipped by:\n")
+	for _, name := range clippers {
+		fmt.Fprintf(&b, "%s\n", name)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,20 @@
+package streamer
+
+import (
+	"k0pern1cus/app/client/twitch"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatChapters(t *testing.T) {
+	chapters := []chapter{
+		{start: 2 * time.Second, clip: twitch.Clip{Title: "first\n clip", BroadcasterName: "alice", CreatorName: "bob"}},
+		{start: 95 * time.Second, clip: twitch.Clip{Title: "second", BroadcasterName: "carol", CreatorName: "bob"}},
+		{start: time.Hour + 5*time.Second, clip: twitch.Clip{Title: "third", BroadcasterName: "alice", CreatorName: "dave"}},
+	}
+
+	require.Equal(t, "0:00 first clip - alice\n1:35 second - carol\n1:00:05 third - alice\n", formatChapters(chapters))
+	require.Equal(t, "Broadcasters:\nalice\ncarol\n\nClipped by:\nbob\ndave\n", formatCredits(chapters))
+}
@@ -0,0 +1,167 @@
+package streamer
+
+import (
+	"context"
+	"fmt"
+	"k0pern1cus/app/service/history"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+var segmentGap = 100 * time.Millisecond
+
+// segment is a ready to air mpegts file that is fed to the output process as is
+type segment struct {
+	path     string
+	duration time.Duration
+	// gap is added to the timestamps after the segment
+	gap time.Duration
+	// clip the segment shows, nil for stingers
+	clip clipHandle
+	// main is set for the clip body, its outcome goes to the play history
+	main bool
+	// boundary is set for the first segment of a rotation item, pause takes effect before it
+	boundary bool
+	// filler is set for the slate, it is aired while there is nothing else to air
+	filler  bool
+	release func()
+}
+
+func (seg *segment) Release() {
+	if seg.release != nil {
+		seg.release()
+	}
+}
+
+func (seg *segment) name() string {
+	if seg.clip != nil {
+		return seg.clip.Clip().ID
+	}
+
+	return filepath.Base(seg.path)
+}
+
+// sequence turns preloaded clips into the list of segments to air
+func (s *Service) sequence(ctx context.Context, segments chan<- *segment) {
+	p := &planner{
+		mode:         s.cfg.Transition.Mode,
+		fadeDuration: s.transitionDuration(),
+		out:          segments,
+		nextClip: func(ctx context.Context) (clipHandle, bool) {
+			clip, ok := s.getNextClip(ctx)
+			if !ok {
+				return nil, false
+			}
+
+			return clip, true
+		},
+		dropClip:   s.dropClip,
+		transition: s.encodeTransition,
+		stinger:    s.stingerSegment,
+		bumper:     s.bumperSegment,
+		bumperDue:  s.bumperDue,
+	}
+
+	p.run(ctx)
+}
+
+func (s *Service) transitionDuration() time.Duration {
+	return time.Duration(s.cfg.Transition.Duration * float64(time.Second))
+}
+
+// dropClip takes a clip that failed to prepare out of the queue and records the failure
+func (s *Service) dropClip(clip clipHandle) {
+	slog.Error("Skipping video due to preparation failure",
+		slog.String("clip_url", clip.Clip().URL),
+	)
+
+	s.dequeue(clip)
+	s.recordOutcome(clip, history.OutcomeFailed)
+}
+
+// encodeTransition blends the previous clip tail into the clip head, returns nil if the transition could not be made
+func (s *Service) encodeTransition(ctx context.Context, prev, clip clipHandle, tail, head string) *segment {
+	output := filepath.Join(s.cfg.DataDir, fmt.Sprintf("transition_%s_%s.ts", prev.Clip().ID, clip.Clip().ID))
+
+	if err := s.encoder.EncodeTransition(ctx, tail, head, output); err != nil {
+		sentry.CaptureException(err)
+		slog.Error("Failed to encode transition, falling back to a cut",
+			slog.String("clip_id", clip.Clip().ID),
+			slog.Any("error", err),
+		)
+		return nil
+	}
+
+	duration, err := s.encoder.ProbeDuration(ctx, output)
+	if err != nil {
+		sentry.CaptureException(err)
+		slog.Error("Failed to measure transition duration, falling back to a cut",
+			slog.String("clip_id", clip.Clip().ID),
+			slog.Any("error", err),
+		)
+		_ = os.Remove(output)
+		return nil
+	}
+
+	return &segment{
+		path:     output,
+		duration: duration,
+		gap:      segmentGap,
+		clip:     clip,
+		boundary: true,
+		release: func() {
+			_ = os.Remove(output)
+		},
+	}
+}
+
+// stingerSegment returns the stinger video encoded with the active profile, it is encoded once on first use
+func (s *Service) stingerSegment(ctx context.Context) *segment {
+	s.stingerOnce.Do(func() {
+		output := filepath.Join(s.cfg.DataDir, "stinger.ts")
+
+		if err := s.encoder.EncodeVideo(ctx, s.cfg.Transition.Stinger, output); err != nil {
+			sentry.CaptureException(err)
+			slog.Error("Failed to encode stinger, falling back to cuts",
+				slog.Any("error", err),
+			)
+			return
+		}
+
+		duration, err := s.encoder.ProbeDuration(ctx, output)
+		if err != nil {
+			sentry.CaptureException(err)
+			slog.Error("Failed to measure stinger duration, falling back to cuts",
+				slog.Any("error", err),
+			)
+			return
+		}
+
+		s.stinger = &segment{
+			path:     output,
+			duration: duration,
+			gap:      segmentGap,
+			boundary: true,
+		}
+	})
+
+	if s.stinger == nil {
+		return nil
+	}
+
+	stinger := *s.stinger
+	return &stinger
+}
+
+func (s *Service) getNextSegment(ctx context.Context, segments <-chan *segment) (*segment, bool) {
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case seg, ok := <-segments:
+		return seg, ok
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io"
 	"k0pern1cus/app/service/clips"
+	"k0pern1cus/app/service/encoder"
+	"k0pern1cus/app/service/history"
 	"k0pern1cus/pkg/config"
+	"k0pern1cus/pkg/ffmpeg"
 	"log/slog"
 	"os/exec"
-	"strings"
 	"sync"
 	"time"
 
@@ -17,147 +19,71 @@ import (
 	"github.com/samber/do"
 )
 
-var fadeDuration = 0.5
 var bufferSizeMB = 10
-var preloadCount = 5
-var preloadWorkerCount = 1
 var artificialOffset = time.Second
 
 type Service struct {
 	cfg          *config.Config
 	clipsService *clips.Service
+	history      *history.Service
+	encoder      *encoder.Service
+
+	// rendering leaves the play history alone, a compilation is not airtime
+	rendering bool
 
 	preloadWg   sync.WaitGroup
+	preloadM    sync.Mutex
+	pendingChan chan pendingClip
 	preloadChan chan *clips.ClipHandle
+
+	m            sync.RWMutex
+	queue        []clipHandle
+	current      clipHandle
+	currentStart time.Time
+	skipCurrent  context.CancelFunc
+	paused       bool
+	resumeChan   chan struct{}
+
+	stingerOnce sync.Once
+	stinger     *segment
+	slateOnce   sync.Once
+	slate       *segment
+	outroOnce   sync.Once
+	outro       *segment
+
+	// bumpers are only touched by the sequencer
+	bumpers   map[int]*segment
+	bumperPos int
 }
 
 func New(di *do.Injector) (*Service, error) {
+	cfg := do.MustInvoke[*config.Config](di)
+
 	return &Service{
-		cfg:          do.MustInvoke[*config.Config](di),
+		cfg:          cfg,
 		clipsService: do.MustInvoke[*clips.Service](di),
-		preloadChan:  make(chan *clips.ClipHandle, preloadCount),
+		history:      do.MustInvoke[*history.Service](di),
+		encoder:      do.MustInvoke[*encoder.Service](di),
+		pendingChan:  make(chan pendingClip, cfg.Stream.PreloadWorkers),
+		preloadChan:  make(chan *clips.ClipHandle, cfg.Stream.PreloadCount),
+		resumeChan:   make(chan struct{}),
+		bumpers:      make(map[int]*segment),
 	}, nil
 }
 
-func (s *Service) startStreamerProcess(ctx context.Context) (io.WriteCloser, *exec.Cmd, error) {
-	localHub := sentry.CurrentHub().Clone()
-
-	span := sentry.StartSpan(ctx, "streamer.streamer_process")
-	defer span.Finish()
-	defer sentry.Recover()
-
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-hide_banner",
-		"-loglevel", "warning",
-		"-threads", "0",
-		"-re",
-		"-f", "mpegts",
-		"-i", "pipe:0",
-		"-c:v", "copy",
-		"-c:a", "copy",
-		"-fflags", "+genpts",
-		"-copyts",
-		"-f", "flv",
-		"-flvflags", "no_duration_filesize",
-		"-max_delay", "1000000",
-		"-rtbufsize", "512M",
-		"-bufsize", "6000k",
-		s.cfg.Twitch.RTMPUrl,
-	)
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		localHub.CaptureException(err)
-		return nil, nil, fmt.Errorf("create stdin pipe: %w", err)
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		localHub.CaptureException(err)
-		return nil, nil, fmt.Errorf("create stderr pipe: %w", err)
-	}
-
-	if err = cmd.Start(); err != nil {
-		localHub.CaptureException(err)
-		return nil, nil, fmt.Errorf("start ffmpeg: %w", err)
-	}
-
-	go s.monitorFFmpegOutput(stderr, "main")
-
-	return stdin, cmd, nil
-}
-
-func (s *Service) monitorFFmpegOutput(stderr io.ReadCloser, processType string) {
-	scanner := bufio.NewScanner(stderr)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		slog.Warn("FFmpeg output",
-			slog.String("process", processType),
-			slog.String("line", line),
-		)
-
-		sentry.AddBreadcrumb(&sentry.Breadcrumb{
-			Category: "ffmpeg",
-			Message:  line,
-			Data: map[string]interface{}{
-				"process_type": processType,
-			},
-			Level: sentry.LevelWarning,
-		})
-	}
-}
-
-func (s *Service) streamVideo(ctx context.Context, clipHandle *clips.ClipHandle, stdin io.WriteCloser, startOffset time.Duration) (time.Duration, error) {
-	span := sentry.StartSpan(ctx, "streamer.stream_video")
+func (s *Service) streamSegment(ctx context.Context, seg *segment, stdin io.WriteCloser, startOffset time.Duration) (time.Duration, history.Outcome, error) {
+	span := sentry.StartSpan(ctx, "streamer.stream_segment")
 	defer span.Finish()
 
-	span.SetTag("clip_id", clipHandle.Clip().ID)
-
-	clip := clipHandle.Clip()
-	filePath, _ := clipHandle.GetDownloadedFile()
-
-	fadeoutStart := clip.Duration - fadeDuration
-	if fadeoutStart < 0 {
-		fadeoutStart = 0
-	}
-
-	filters := []string{
-		fmt.Sprintf("fade=t=in:st=0:d=%.2f", fadeDuration),
-		fmt.Sprintf("fade=t=out:st=%.2f:d=%.2f", fadeoutStart, fadeDuration),
-		"scale=1920:1080:flags=lanczos:force_original_aspect_ratio=decrease",
-		"pad=1920:1080:(ow-iw)/2:(oh-ih)/2:color=black",
-	}
-
-	escapedTitle := fmt.Sprintf("%s - %s", clip.BroadcasterName, clip.Title)
-	escapedTitle = strings.ReplaceAll(escapedTitle, "'", "'\\''")
-	escapedTitle = strings.ReplaceAll(escapedTitle, ":", "\\:")
-	filters = append(filters, fmt.Sprintf("drawtext=text='%s':fontfile=/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf:x=w-text_w-20:y=20:fontsize=28:fontcolor=white:shadowcolor=black:shadowx=2:shadowy=2", escapedTitle))
+	span.SetTag("segment", seg.name())
 
+	// the segment is already encoded, only the timestamps need to be shifted
 	args := []string{
 		"-hide_banner",
 		"-loglevel", "warning",
-		"-threads", "0",
-		"-i", filePath,
-		"-vf", strings.Join(filters, ","),
-		"-c:v", "libx264",
-		"-preset", "fast",
-		"-tune", "zerolatency",
-		"-profile:v", "main",
-		"-b:v", "6000k",
-		"-maxrate", "6000k",
-		"-minrate", "6000k",
-		"-bufsize", "12000k",
-		"-r", "60",
-		"-g", "120",
-		"-keyint_min", "120",
-		"-pix_fmt", "yuv420p",
-		"-x264opts", "nal-hrd=cbr:force-cfr=1",
+		"-i", seg.path,
+		"-c", "copy",
 		"-output_ts_offset", fmt.Sprintf("%.6f", startOffset.Seconds()),
-		"-c:a", "aac",
-		"-b:a", "160k",
-		"-ar", "44100",
-		"-ac", "2",
 		"-f", "mpegts",
 		"-mpegts_flags", "initial_discontinuity",
 		"-flush_packets", "1",
@@ -168,25 +94,33 @@ func (s *Service) streamVideo(ctx context.Context, clipHandle *clips.ClipHandle,
 		"-",
 	}
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	clipCtx, skip := context.WithCancel(ctx)
+	defer skip()
+
+	s.setCurrent(seg.clip, skip)
+	defer s.setCurrent(nil, nil)
+
+	beginTime := time.Now()
+
+	cmd := exec.CommandContext(clipCtx, "ffmpeg", args...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		sentry.CaptureException(err)
-		return 0, fmt.Errorf("create stdout pipe: %w", err)
+		return 0, history.OutcomeFailed, fmt.Errorf("create stdout pipe: %w", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		sentry.CaptureException(err)
-		return 0, fmt.Errorf("create stderr pipe: %w", err)
+		return 0, history.OutcomeFailed, fmt.Errorf("create stderr pipe: %w", err)
 	}
 
-	go s.monitorFFmpegOutput(stderr, clip.ID)
+	go ffmpeg.MonitorOutput(stderr, seg.name())
 
 	if err = cmd.Start(); err != nil {
 		sentry.CaptureException(err)
-		return 0, fmt.Errorf("start ffmpeg: %w", err)
+		return 0, history.OutcomeFailed, fmt.Errorf("start ffmpeg: %w", err)
 	}
 
 	buf := make([]byte, bufferSizeMB*1024*1024)
@@ -196,51 +130,123 @@ func (s *Service) streamVideo(ctx context.Context, clipHandle *clips.ClipHandle,
 	if err != nil {
 		_ = cmd.Process.Kill()
 		sentry.CaptureException(err)
-		return 0, fmt.Errorf("copy video data: %w", err)
+		return 0, history.OutcomeFailed, fmt.Errorf("copy video data: %w", err)
 	}
 
-	if err = cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	if clipCtx.Err() != nil && ctx.Err() == nil {
+		slog.Info("Segment skipped",
+			slog.String("segment", seg.name()),
+		)
+
+		// ffmpeg feeds the main process in real time, so the elapsed time is the part that made it on air
+		played := min(time.Since(beginTime), seg.duration)
+		return startOffset + played + seg.gap, history.OutcomeSkipped, nil
+	}
+	if err != nil {
 		sentry.CaptureException(err)
-		return 0, fmt.Errorf("ffmpeg processing: %w", err)
+		return 0, history.OutcomeFailed, fmt.Errorf("ffmpeg processing: %w", err)
 	}
 
-	return startOffset + clipHandle.GetPreciseDuration() + artificialOffset, nil
+	return startOffset + seg.duration + seg.gap, history.OutcomePlayed, nil
+}
+
+// pendingClip is a clip taken from the pool that is still being prepared
+type pendingClip struct {
+	clip  *clips.ClipHandle
+	ready chan struct{}
 }
 
 func (s *Service) preloadWorker(ctx context.Context) {
 	defer s.preloadWg.Done()
 
 	for {
+		// clips are queued for the handoff in the order they are taken from the pool
+		s.preloadM.Lock()
+
 		clip, ok := s.clipsService.RemoveClip()
 		if !ok {
+			s.preloadM.Unlock()
 			return
 		}
 
 		readyChan := clip.PrepareAsync(ctx)
 
+		select {
+		case <-ctx.Done():
+			s.preloadM.Unlock()
+			return
+		case s.pendingChan <- pendingClip{clip: clip, ready: readyChan}:
+		}
+
+		s.preloadM.Unlock()
+
 		select {
 		case <-ctx.Done():
 			return
 		case <-readyChan:
-			select {
-			case <-ctx.Done():
-				return
-			case s.preloadChan <- clip:
-			}
 		}
 	}
 }
 
+// handoffPreloaded passes the prepared clips on in the order they were taken from the pool,
+// a clip that is prepared early waits for the ones selected before it
+func (s *Service) handoffPreloaded(ctx context.Context) {
+	defer close(s.preloadChan)
+
+	for pending := range s.pendingChan {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pending.ready:
+		}
+
+		if reason, rejected := pending.clip.Rejected(); rejected {
+			s.rejectClip(pending.clip, reason)
+			continue
+		}
+
+		s.enqueue(pending.clip)
+
+		select {
+		case <-ctx.Done():
+			return
+		case s.preloadChan <- pending.clip:
+		}
+	}
+}
+
+// rejectClip drops a clip that failed the quality gate before it is queued
+func (s *Service) rejectClip(clip *clips.ClipHandle, reason string) {
+	slog.Warn("Clip rejected by quality gate",
+		slog.String("clip_id", clip.Clip().ID),
+		slog.String("clip_url", clip.Clip().URL),
+		slog.String("reason", reason),
+	)
+
+	if err := s.clipsService.Reject(clip, reason); err != nil {
+		sentry.CaptureException(err)
+		slog.Error("Failed to record clip rejection",
+			slog.String("clip_id", clip.Clip().ID),
+			slog.Any("error", err),
+		)
+	}
+
+	clip.Release()
+}
+
 func (s *Service) startPreloadWorkers(ctx context.Context) {
-	for i := 0; i < preloadWorkerCount; i++ {
+	for i := 0; i < s.cfg.Stream.PreloadWorkers; i++ {
 		s.preloadWg.Add(1)
 		go s.preloadWorker(ctx)
 	}
 
 	go func() {
 		s.preloadWg.Wait()
-		close(s.preloadChan)
+		close(s.pendingChan)
 	}()
+
+	go s.handoffPreloaded(ctx)
 }
 
 func (s *Service) getNextClip(ctx context.Context) (*clips.ClipHandle, bool) {
@@ -257,51 +263,180 @@ func (s *Service) Run(ctx context.Context) error {
 	defer span.Finish()
 	defer sentry.Recover()
 
+	// destinations are only needed to go live, a render-only config can leave them out
+	if len(s.cfg.Stream.Destinations) == 0 {
+		return fmt.Errorf("no stream destinations configured")
+	}
+
 	slog.Info("Starting the stream...")
 
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	s.startPreloadWorkers(ctx)
+
+	// the outro is needed just before the stream deadline
+	if s.cfg.Outro.Enabled {
+		go s.outroSegment(ctx)
+	}
+
+	// the slate is needed as soon as the first clip takes too long
+	s.slateSegment(ctx)
+
+	// held is the segment that is aired before the next one from the sequencer,
+	// the countdown at start or the one waiting for the rotation to be resumed while the slate is aired
+	held := s.countdownSegment(ctx)
 
-	stdin, cmd, err := s.startStreamerProcess(ctx)
+	out, err := s.startStreamerProcess(ctx)
 	if err != nil {
 		sentry.CaptureException(err)
 		return fmt.Errorf("start streamer process: %w", err)
 	}
-	defer stdin.Close()
-
-	go func() {
-		_ = cmd.Wait()
-		cancel()
+	defer func() {
+		out.Close()
 	}()
 
-	s.startPreloadWorkers(ctx)
+	segments := make(chan *segment)
+	go s.sequence(ctx, segments)
 
 	var currentOffset time.Duration
+	var restartAttempt int
+	var skippedClip clipHandle
+	var starving bool
 
 	for {
-		clip, ok := s.getNextClip(ctx)
-		if !ok {
-			return fmt.Errorf("no clips available")
-		}
+		if out.Dead() {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 
-		if _, downloadOk := clip.GetDownloadedFile(); downloadOk {
-			slog.Info("Streaming video",
-				slog.String("clip_url", clip.Clip().URL),
-			)
+			if time.Since(out.startedAt) > stableOutputDuration {
+				restartAttempt = 0
+			}
 
-			newOffset, err := s.streamVideo(ctx, clip, stdin, currentOffset)
+			out, err = s.restartStreamerProcess(ctx, out, restartAttempt)
 			if err != nil {
-				sentry.CaptureException(err)
-				return fmt.Errorf("stream video: %w", err)
+				return fmt.Errorf("restart streamer process: %w", err)
 			}
 
-			currentOffset = newOffset
-		} else {
-			slog.Error("Skipping video due to download failure",
-				slog.String("clip_url", clip.Clip().URL),
+			restartAttempt++
+			// the new process starts with a fresh timestamp base
+			currentOffset = 0
+		}
+
+		seg := held
+		held = nil
+
+		if seg == nil {
+			var ok bool
+
+			seg, ok = s.nextSegment(ctx, segments, starving)
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				return fmt.Errorf("no clips available")
+			}
+
+			starving = seg.filler
+		}
+
+		if seg.boundary && s.IsPaused() {
+			if slate := s.slateSegment(ctx); slate != nil {
+				held = seg
+				seg = slate
+			} else {
+				// holding the rotation without the slate would starve the output
+				slog.Warn("The slate failed to encode, resuming the rotation")
+				s.Resume()
+			}
+		}
+
+		if outro := s.outroDue(ctx, seg); outro != nil {
+			seg.Release()
+			if held != nil {
+				held.Release()
+			}
+
+			return s.streamOutro(ctx, out, outro, currentOffset)
+		}
+
+		// skipping the lead-in of a clip skips the clip body as well
+		if seg.main && seg.clip == skippedClip {
+			s.recordOutcome(seg.clip, history.OutcomeSkipped)
+			seg.Release()
+			continue
+		}
+
+		if seg.clip != nil {
+			s.dequeue(seg.clip)
+
+			if seg.main {
+				slog.Info("Streaming video",
+					slog.String("clip_url", seg.clip.Clip().URL),
+				)
+			}
+		}
+
+		newOffset, outcome, err := s.streamSegment(ctx, seg, out.stdin, currentOffset)
+		if err != nil {
+			if ctx.Err() != nil {
+				seg.Release()
+				if held != nil {
+					held.Release()
+				}
+				return ctx.Err()
+			}
+
+			sentry.CaptureException(err)
+			slog.Error("Failed to stream segment",
+				slog.String("segment", seg.name()),
+				slog.Bool("output_dead", out.Dead()),
+				slog.Any("error", err),
 			)
+			outcome = history.OutcomeFailed
+		} else {
+			currentOffset = newOffset
+		}
+
+		if outcome == history.OutcomeSkipped && !seg.main {
+			skippedClip = seg.clip
+		}
+
+		if seg.main {
+			s.recordOutcome(seg.clip, outcome)
 		}
 
-		clip.Release()
+		seg.Release()
+	}
+}
+
+// streamOutro airs the outro and waits for the output to drain, the stream is over after it
+func (s *Service) streamOutro(ctx context.Context, out *outputProcess, outro *segment, offset time.Duration) error {
+	slog.Info("Streaming the outro")
+
+	if _, _, err := s.streamSegment(ctx, outro, out.stdin, offset); err != nil {
+		return fmt.Errorf("stream outro: %w", err)
+	}
+
+	out.Close()
+
+	select {
+	case <-ctx.Done():
+	case <-out.done:
+	}
+
+	return nil
+}
+
+func (s *Service) recordOutcome(clip clipHandle, outcome history.Outcome) {
+	if s.rendering {
+		return
+	}
+
+	if err := s.history.Record(clip.Clip().ID, outcome); err != nil {
+		sentry.CaptureException(err)
+		slog.Error("Failed to record play history",
+			slog.String("clip_id", clip.Clip().ID),
+			slog.Any("error", err),
+		)
 	}
 }
@@ -0,0 +1,62 @@
+package streamer
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// slateSegment returns the slate encoded with the active profile, it is encoded once on first use
+func (s *Service) slateSegment(ctx context.Context) *segment {
+	if !s.cfg.Slate.Enabled {
+		return nil
+	}
+
+	s.slateOnce.Do(func() {
+		s.slate = s.encodeCardSegment(ctx, "slate", func(output string) error {
+			return s.encoder.EncodeCard(ctx, s.cfg.Slate.Card, output)
+		})
+		if s.slate != nil {
+			s.slate.boundary = false
+			s.slate.filler = true
+		}
+	})
+
+	if s.slate == nil {
+		return nil
+	}
+
+	slate := *s.slate
+	return &slate
+}
+
+// nextSegment waits for the next segment to air, the slate is aired instead if none arrives before the deadline.
+// Once the output is starving the slate is looped until a segment is ready
+func (s *Service) nextSegment(ctx context.Context, segments <-chan *segment, starving bool) (*segment, bool) {
+	slate := s.slateSegment(ctx)
+	if slate == nil {
+		return s.getNextSegment(ctx, segments)
+	}
+
+	if starving {
+		select {
+		case seg, ok := <-segments:
+			return seg, ok
+		default:
+			return slate, true
+		}
+	}
+
+	deadline := time.NewTimer(s.cfg.Slate.Deadline)
+	defer deadline.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case seg, ok := <-segments:
+		return seg, ok
+	case <-deadline.C:
+		slog.Warn("No clip is ready in time, airing the slate")
+		return slate, true
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"k0pern1cus/app/service/clips"
+	"k0pern1cus/app/service/encoder"
+	"k0pern1cus/app/service/history"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/do"
+)
+
+// fetchCatalog loads the play history, so rejected clips stay out, and the whole clip catalog
+func fetchCatalog(ctx context.Context, di *do.Injector) (*clips.Service, error) {
+	if err := resolveNames(ctx, di); err != nil {
+		return nil, err
+	}
+
+	if err := do.MustInvoke[*history.Service](di).Init(ctx); err != nil {
+		return nil, fmt.Errorf("history service init: %w", err)
+	}
+
+	clipsService := do.MustInvoke[*clips.Service](di)
+	if err := clipsService.Fetch(ctx); err != nil {
+		return nil, fmt.Errorf("fetch clips: %w", err)
+	}
+
+	return clipsService, nil
+}
+
+func runFetch(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("fetch")
+	output := flags.String("output", "-", "catalog file, - for stdout")
+	_ = flags.Parse(args)
+
+	di, _, err := setup(ctx, common)
+	if err != nil {
+		return err
+	}
+	defer teardown(di)
+
+	clipsService, err := fetchCatalog(ctx, di)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer file.Close()
+
+		out = file
+	}
+
+	jsonEncoder := json.NewEncoder(out)
+	for _, clip := range clipsService.Catalog() {
+		if err = jsonEncoder.Encode(clip); err != nil {
+			return fmt.Errorf("write clip: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func runValidateConfig(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("validate-config")
+	_ = flags.Parse(args)
+
+	di, cfg, err := setup(ctx, common)
+	if err != nil {
+		return err
+	}
+	defer teardown(di)
+
+	// the overlay and title card templates and the selector are checked when the services are created
+	if _, err = do.Invoke[*encoder.Service](di); err != nil {
+		return fmt.Errorf("encoder: %w", err)
+	}
+
+	if _, err = do.Invoke[*clips.Service](di); err != nil {
+		return fmt.Errorf("clips: %w", err)
+	}
+
+	// names are looked up on Twitch, so unknown broadcasters and games are caught here as well
+	if err = resolveNames(ctx, di); err != nil {
+		return err
+	}
+
+	fmt.Printf("Config %s is valid\n", common.configPath)
+	fmt.Printf("Encoding profile: %s\n", cfg.Encoding.Profile)
+	fmt.Printf("Broadcasters: %s\n", strings.Join(cfg.Twitch.BroadcasterIDs, ", "))
+	fmt.Printf("Game: %s\n", cfg.Twitch.GameID)
+
+	if len(cfg.Stream.Destinations) == 0 {
+		fmt.Println("No stream destinations, only render is going to work")
+	}
+
+	for _, destination := range cfg.Stream.Destinations {
+		fmt.Printf("Destination %s: %s\n", destination.Name, destination.ResolveFormat())
+	}
+
+	return nil
+}
+
+func runListClips(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("list-clips")
+	_ = flags.Parse(args)
+
+	di, _, err := setup(ctx, common)
+	if err != nil {
+		return err
+	}
+	defer teardown(di)
+
+	clipsService, err := fetchCatalog(ctx, di)
+	if err != nil {
+		return err
+	}
+
+	historyService := do.MustInvoke[*history.Service](di)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tBROADCASTER\tCREATOR\tVIEWS\tDURATION\tCREATED\tSTATUS\tTITLE")
+
+	for _, clip := range clipsService.Catalog() {
+		status := "ready"
+		if historyService.InCooldown(clip.ID) {
+			status = "cooldown"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.1fs\t%s\t%s\t%s\n",
+			clip.ID,
+			clip.BroadcasterName,
+			clip.CreatorName,
+			clip.ViewCount,
+			clip.Duration,
+			clip.CreatedAt.Format(time.DateOnly),
+			status,
+			clip.Title,
+		)
+	}
+
+	return w.Flush()
+}
+
+func runProbe(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("probe")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected a single clip slug, url or file")
+	}
+
+	di, cfg, err := setup(ctx, common)
+	if err != nil {
+		return err
+	}
+	defer teardown(di)
+
+	// the data directory may be in use by a running stream, so the clip is probed in a directory of its own
+	cfg.DataDir, err = os.MkdirTemp("", "k0pern1cus-probe-")
+	if err != nil {
+		return fmt.Errorf("create data directory: %w", err)
+	}
+	defer os.RemoveAll(cfg.DataDir)
+
+	report, err := do.MustInvoke[*clips.Service](di).Probe(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range report.Probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			fmt.Printf("Video: %s %dx%d %.2f fps\n", stream.CodecName, stream.Width, stream.Height, stream.FrameRate())
+		case "audio":
+			fmt.Printf("Audio: %s\n", stream.CodecName)
+		}
+	}
+
+	fmt.Printf("Duration: %.2fs\n", report.Analysis.Duration)
+	fmt.Printf("Black: %.0f%%\n", report.Analysis.BlackRatio()*100)
+	fmt.Printf("Frozen: %.0f%%\n", report.Analysis.FrozenRatio()*100)
+	fmt.Printf("Silent: %.0f%%\n", report.Analysis.SilentRatio()*100)
+
+	if report.Trim != nil {
+		fmt.Printf("Trim: %.2fs - %.2fs\n", report.Trim.Start, report.Trim.End)
+	} else {
+		fmt.Println("Trim: nothing to cut")
+	}
+
+	if report.RejectReason != "" {
+		fmt.Printf("Quality gate: rejected, %s\n", report.RejectReason)
+	} else {
+		fmt.Println("Quality gate: passed")
+	}
+
+	return nil
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"k0pern1cus/app/api"
 	"k0pern1cus/app/client/clip_downloader"
 	"k0pern1cus/app/client/twitch"
 	"k0pern1cus/app/service/clips"
+	"k0pern1cus/app/service/encoder"
+	"k0pern1cus/app/service/history"
 	"k0pern1cus/app/service/streamer"
 	"k0pern1cus/pkg/config"
 	sentry2 "k0pern1cus/pkg/sentry"
@@ -12,6 +17,7 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"text/tabwriter"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -19,61 +25,247 @@ import (
 	"github.com/samber/do"
 )
 
+// command is a CLI subcommand, run gets the arguments after the subcommand name
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"stream", "", "stream clips to the destinations (default)", runStream},
+	{"fetch", "", "fetch the clip catalog and write it as JSON lines", runFetch},
+	{"render", "", "render a compilation of clips to a file", runRender},
+	{"validate-config", "", "load and validate the config", runValidateConfig},
+	{"list-clips", "", "fetch the clip catalog and list the clips with their cooldown status", runListClips},
+	{"probe", "<clip slug, url or file>", "run the quality gate and the trim detection on a clip", runProbe},
+}
+
 func main() {
 	appCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// stop the stream after a day
-	appCtx, cancel1 := context.WithTimeout(appCtx, 24*time.Hour)
-	defer cancel1()
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+
+		log.Info("Shutting down...")
 
-	_ = os.RemoveAll("data")
-	_ = os.Mkdir("data", os.ModePerm)
+		cancel()
+	}()
 
-	di := do.New()
-	do.ProvideValue(di, appCtx)
+	// no subcommand streams, like before subcommands existed
+	name, args := "stream", os.Args[1:]
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		if err := cmd.run(appCtx, args); err != nil {
+			log.Fatalf("%s failed: %v", name, err)
+		}
+
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(w, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.description)
+	}
+	_ = w.Flush()
+
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the command flags\n", os.Args[0])
+}
+
+// commonFlags are accepted by every command
+type commonFlags struct {
+	configPath string
+	dataDir    string
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *commonFlags) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	common := &commonFlags{}
+	flags.StringVar(&common.configPath, "config", "config.yaml", "config file path")
+	flags.StringVar(&common.dataDir, "data", "", "directory for downloaded and encoded clips, overrides data_dir")
+
+	return flags, common
+}
 
-	cfg, err := config.Load()
+// setup loads the config, initializes logging and registers the services, nothing is started yet
+func setup(ctx context.Context, common *commonFlags) (*do.Injector, *config.Config, error) {
+	cfg, err := config.Load(common.configPath)
 	if err != nil {
-		log.Fatalf("config load failed: %v", err)
+		return nil, nil, fmt.Errorf("config load: %w", err)
+	}
+
+	if common.dataDir != "" {
+		cfg.DataDir = common.dataDir
 	}
-	do.ProvideValue(di, cfg)
 
 	if err = tlog.Init(cfg); err != nil {
-		log.Fatalf("logging init failed: %v", err)
+		return nil, nil, fmt.Errorf("logging init: %w", err)
 	}
 
 	if err = sentry2.Init(cfg); err != nil {
 		slog.Error("Sentry initialization failed", slog.Any("error", err))
 	}
-	defer sentry.Flush(time.Second)
-	defer sentry.RecoverWithContext(appCtx)
 
-	slog.ErrorContext(appCtx, "Service restarted")
+	di := do.New()
+	do.ProvideValue(di, ctx)
+	do.ProvideValue(di, cfg)
 
 	do.Provide(di, twitch.NewClient)
 	do.Provide(di, clip_downloader.New)
+	do.Provide(di, encoder.New)
+	do.Provide(di, history.New)
 	do.Provide(di, clips.New)
 	do.Provide(di, streamer.New)
+	do.Provide(di, api.New)
 
-	go func() {
-		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint, os.Interrupt)
-		<-sigint
+	return di, cfg, nil
+}
 
-		log.Info("Shutting down server...")
+// teardown shuts the services down and sends the captured errors before the command exits
+func teardown(di *do.Injector) {
+	_ = di.Shutdown()
+	sentry.Flush(time.Second)
+}
 
-		cancel()
+// resetDataDir starts from an empty data directory, leftovers of a previous run are never reused
+func resetDataDir(cfg *config.Config) error {
+	_ = os.RemoveAll(cfg.DataDir)
+
+	if err := os.MkdirAll(cfg.DataDir, os.ModePerm); err != nil {
+		return fmt.Errorf("create data directory: %w", err)
+	}
+
+	return nil
+}
+
+// resolveNames turns the broadcaster logins and the game name of the config into ids
+func resolveNames(ctx context.Context, di *do.Injector) error {
+	if err := do.MustInvoke[*twitch.Client](di).ResolveConfig(ctx); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	return nil
+}
+
+// initClips loads the play history and starts fetching the clip catalog
+func initClips(ctx context.Context, di *do.Injector) error {
+	if err := resolveNames(ctx, di); err != nil {
+		return err
+	}
+
+	if err := do.MustInvoke[*history.Service](di).Init(ctx); err != nil {
+		return fmt.Errorf("history service init: %w", err)
+	}
+
+	if err := do.MustInvoke[*clips.Service](di).Init(ctx); err != nil {
+		return fmt.Errorf("clip service init: %w", err)
+	}
+
+	return nil
+}
+
+func runStream(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("stream")
+	duration := flags.Duration("duration", 24*time.Hour, "stop the stream after this long, 0 streams until interrupted")
+	_ = flags.Parse(args)
+
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	di, cfg, err := setup(ctx, common)
+	if err != nil {
+		return err
+	}
+	defer sentry.Flush(time.Second)
+	defer sentry.RecoverWithContext(ctx)
+
+	if err = resetDataDir(cfg); err != nil {
+		return err
+	}
+
+	slog.ErrorContext(ctx, "Service restarted")
+
+	go func() {
+		if err := do.MustInvoke[*api.Server](di).Run(ctx); err != nil {
+			slog.Error("API server failed", slog.Any("error", err))
+		}
 	}()
 
-	if err = do.MustInvoke[*clips.Service](di).Init(appCtx); err != nil {
-		log.Fatalf("clip service init failed: %v", err)
+	if err = initClips(ctx, di); err != nil {
+		return err
 	}
 
-	if err = do.MustInvoke[*streamer.Service](di).Run(appCtx); err != nil {
-		log.Fatalf("streaming failed: %v", err)
+	if err = do.MustInvoke[*streamer.Service](di).Run(ctx); err != nil {
+		return fmt.Errorf("streaming: %w", err)
 	}
 
 	log.Info("Waiting for services to finish...")
 	_ = di.Shutdown()
+
+	return nil
+}
+
+func runRender(ctx context.Context, args []string) error {
+	flags, common := newFlagSet("render")
+	output := flags.String("output", "", "compilation file, overrides render.output")
+	clipCount := flags.Int("clips", 0, "number of clips, overrides render.clips")
+	duration := flags.Duration("duration", 0, "target compilation duration, overrides render.duration")
+	_ = flags.Parse(args)
+
+	di, cfg, err := setup(ctx, common)
+	if err != nil {
+		return err
+	}
+	defer sentry.Flush(time.Second)
+	defer sentry.RecoverWithContext(ctx)
+
+	if *output != "" {
+		cfg.Render.Output = *output
+	}
+	// either flag replaces both limits from the config
+	if *clipCount > 0 || *duration > 0 {
+		cfg.Render.Clips = *clipCount
+		cfg.Render.Duration = *duration
+	}
+
+	// a clip is never repeated within the compilation, it ends early once the pool is empty
+	cfg.Clips.ExhaustionPolicy = clips.ExhaustionStop
+
+	if err = resetDataDir(cfg); err != nil {
+		return err
+	}
+
+	if err = initClips(ctx, di); err != nil {
+		return err
+	}
+
+	if err = do.MustInvoke[*streamer.Service](di).Render(ctx); err != nil {
+		return fmt.Errorf("rendering: %w", err)
+	}
+
+	_ = di.Shutdown()
+
+	return nil
 }
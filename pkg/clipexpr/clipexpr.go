@@ -0,0 +1,71 @@
+package clipexpr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Env exposes the clip fields to the expressions under their API names, e.g.
+// view_count > 500 && duration < 45 && language == "ru"
+type Env struct {
+	ID              string    `expr:"id"`
+	URL             string    `expr:"url"`
+	BroadcasterID   string    `expr:"broadcaster_id"`
+	BroadcasterName string    `expr:"broadcaster_name"`
+	CreatorID       string    `expr:"creator_id"`
+	CreatorName     string    `expr:"creator_name"`
+	VideoID         string    `expr:"video_id"`
+	GameID          string    `expr:"game_id"`
+	Language        string    `expr:"language"`
+	Title           string    `expr:"title"`
+	ViewCount       int       `expr:"view_count"`
+	CreatedAt       time.Time `expr:"created_at"`
+	Duration        float64   `expr:"duration"`
+	VodOffset       int       `expr:"vod_offset"`
+	IsFeatured      bool      `expr:"is_featured"`
+	// AgeHours is the time since the clip was created
+	AgeHours float64 `expr:"age_hours"`
+}
+
+// CompileFilter compiles an expression that has to evaluate to a bool
+func CompileFilter(source string) (*vm.Program, error) {
+	program, err := expr.Compile(source, expr.Env(Env{}), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("compile filter: %w", err)
+	}
+
+	return program, nil
+}
+
+// CompileScore compiles an expression that has to evaluate to a number
+func CompileScore(source string) (*vm.Program, error) {
+	program, err := expr.Compile(source, expr.Env(Env{}), expr.AsFloat64())
+	if err != nil {
+		return nil, fmt.Errorf("compile score: %w", err)
+	}
+
+	return program, nil
+}
+
+// Filter runs a program compiled with CompileFilter
+func Filter(program *vm.Program, env Env) (bool, error) {
+	result, err := expr.Run(program, env)
+	if err != nil {
+		return false, err
+	}
+
+	return result.(bool), nil
+}
+
+// Score runs a program compiled with CompileScore
+func Score(program *vm.Program, env Env) (float64, error) {
+	result, err := expr.Run(program, env)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.(float64), nil
+}
@@ -0,0 +1,51 @@
+package clipexpr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	program, err := CompileFilter(`view_count > 500 && duration < 45 && language == "ru"`)
+	require.NoError(t, err)
+
+	ok, err := Filter(program, Env{ViewCount: 600, Duration: 30, Language: "ru"})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = Filter(program, Env{ViewCount: 600, Duration: 50, Language: "ru"})
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = Filter(program, Env{})
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = CompileFilter(`view_count + 1`)
+	require.Error(t, err)
+
+	_, err = CompileFilter(`views > 1`)
+	require.Error(t, err)
+}
+
+func TestScore(t *testing.T) {
+	program, err := CompileScore(`view_count / max(age_hours, 1)`)
+	require.NoError(t, err)
+
+	score, err := Score(program, Env{ViewCount: 100, AgeHours: 4})
+	require.NoError(t, err)
+	require.InDelta(t, 25, score, 1e-9)
+
+	// integer results are accepted as well
+	program, err = CompileScore(`view_count`)
+	require.NoError(t, err)
+
+	score, err = Score(program, Env{ViewCount: 7, CreatedAt: time.Now()})
+	require.NoError(t, err)
+	require.InDelta(t, 7, score, 1e-9)
+
+	_, err = CompileScore(`title`)
+	require.Error(t, err)
+}
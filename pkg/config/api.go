@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// setupAPI refuses to expose the control API without a token, only a loopback address may leave it open
+func (c *Config) setupAPI() error {
+	if c.API.Token != "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(c.API.Listen)
+	if err != nil {
+		return fmt.Errorf("invalid api listen address %s: %w", c.API.Listen, err)
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+
+	return fmt.Errorf("api listens on %s without a token, set api.token or listen on a loopback address", c.API.Listen)
+}
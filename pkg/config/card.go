@@ -0,0 +1,62 @@
+package config
+
+import "time"
+
+// Card is a locally rendered video: a still image, a video or a black card with optional text and background audio.
+// Duration is in seconds, a video without duration is played once
+type Card struct {
+	Image    string  `yaml:"image" validate:"excluded_with=Video"`
+	Video    string  `yaml:"video"`
+	Audio    string  `yaml:"audio"`
+	Text     string  `yaml:"text"`
+	FontFile string  `yaml:"font_file"`
+	Duration float64 `yaml:"duration" validate:"gte=0"`
+}
+
+// every line of the title card is drawn separately, the first one is the largest
+const defaultTitleCardText = `{{.broadcaster_name}}
+{{truncate 60 .title}}
+clipped by {{.creator_name}} · {{date "January 2, 2006" .created_at}}`
+
+func (c *Card) applyDefaults(text string, duration float64) {
+	if c.FontFile == "" {
+		c.FontFile = DefaultFontFile
+	}
+
+	// a plain black card says what is going on
+	if c.Image == "" && c.Video == "" && c.Text == "" {
+		c.Text = text
+	}
+
+	if c.Video == "" && c.Duration == 0 {
+		c.Duration = duration
+	}
+}
+
+func (c *Config) setupCards() {
+	c.Slate.applyDefaults("Be right back", 5)
+	if c.Slate.Deadline == 0 {
+		c.Slate.Deadline = 3 * time.Second
+	}
+
+	c.Countdown.applyDefaults("Starting soon", 300)
+	if c.Countdown.Duration == 0 {
+		c.Countdown.Duration = 300
+	}
+
+	c.Outro.applyDefaults("Thanks for watching", 10)
+
+	if c.TitleCard.Text == "" {
+		c.TitleCard.Text = defaultTitleCardText
+	}
+	if c.TitleCard.FontFile == "" {
+		c.TitleCard.FontFile = DefaultFontFile
+	}
+	if c.TitleCard.Duration == 0 {
+		c.TitleCard.Duration = 3
+	}
+
+	for i := range c.Bumpers.Items {
+		c.Bumpers.Items[i].applyDefaults("", 5)
+	}
+}
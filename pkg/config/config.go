@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/go-playground/validator/v10"
@@ -11,6 +12,9 @@ import (
 )
 
 type Config struct {
+	// DataDir holds the downloaded and encoded clips, it is wiped on stream start
+	DataDir string `yaml:"data_dir"`
+
 	Log struct {
 		Telegram struct {
 			Token  string `yaml:"token"`
@@ -25,20 +29,127 @@ type Config struct {
 	} `yaml:"sentry"`
 
 	Twitch struct {
-		BroadcasterIDs []string `yaml:"broadcaster_ids" validate:"required"`
-		GameID         string   `yaml:"game_id" validate:"required"`
+		// Broadcasters and Game are resolved into BroadcasterIDs and GameID on start,
+		// clips of GameID and any of GameIDs are eligible
+		BroadcasterIDs []string `yaml:"broadcaster_ids" validate:"required_without=Broadcasters"`
+		Broadcasters   []string `yaml:"broadcasters"`
+		GameID         string   `yaml:"game_id" validate:"required_without_all=Game GameIDs"`
+		Game           string   `yaml:"game" validate:"excluded_with=GameID"`
+		GameIDs        []string `yaml:"game_ids"`
 		MinDate        string   `yaml:"min_date" validate:"required"`
 		ClientID       string   `yaml:"client_id" validate:"required"`
 		ClientSecret   string   `yaml:"client_secret" validate:"required"`
 		RTMPUrl        string   `yaml:"rtmp_url"`
 	} `yaml:"twitch"`
+
+	Stream struct {
+		PreloadCount   int           `yaml:"preload_count" validate:"gte=1"`
+		PreloadWorkers int           `yaml:"preload_workers" validate:"gte=1"`
+		Destinations   []Destination `yaml:"destinations" validate:"dive"`
+	} `yaml:"stream"`
+
+	Encoding struct {
+		Profile  string                     `yaml:"profile"`
+		Profiles map[string]EncodingProfile `yaml:"profiles" validate:"dive"`
+	} `yaml:"encoding"`
+
+	Audio struct {
+		Loudnorm struct {
+			Enabled   bool    `yaml:"enabled"`
+			TargetI   float64 `yaml:"target_i" validate:"gte=-70,lte=-5"`
+			TargetTP  float64 `yaml:"target_tp" validate:"gte=-9,lte=0"`
+			TargetLRA float64 `yaml:"target_lra" validate:"gte=1,lte=50"`
+		} `yaml:"loudnorm"`
+	} `yaml:"audio"`
+
+	Quality struct {
+		Enabled        bool    `yaml:"enabled"`
+		MinHeight      int     `yaml:"min_height" validate:"gte=0"`
+		MinFPS         float64 `yaml:"min_fps" validate:"gte=0"`
+		RequireAudio   bool    `yaml:"require_audio"`
+		MaxBlackRatio  float64 `yaml:"max_black_ratio" validate:"gte=0,lte=1"`
+		MaxFrozenRatio float64 `yaml:"max_frozen_ratio" validate:"gte=0,lte=1"`
+		MaxSilentRatio float64 `yaml:"max_silent_ratio" validate:"gte=0,lte=1"`
+	} `yaml:"quality"`
+
+	Trim struct {
+		Enabled     bool    `yaml:"enabled"`
+		MaxStart    float64 `yaml:"max_start" validate:"gte=0"`
+		MaxEnd      float64 `yaml:"max_end" validate:"gte=0"`
+		MinDuration float64 `yaml:"min_duration" validate:"gte=0"`
+	} `yaml:"trim"`
+
+	Transition struct {
+		Mode     string  `yaml:"mode" validate:"oneof=fade crossfade stinger"`
+		Duration float64 `yaml:"duration" validate:"gt=0,lte=5"`
+		Effect   string  `yaml:"effect" validate:"oneof=fade wipeleft wiperight wipeup wipedown slideleft slideright slideup slidedown circlecrop rectcrop distance fadeblack fadewhite radial smoothleft smoothright smoothup smoothdown circleopen circleclose vertopen vertclose horzopen horzclose dissolve pixelize diagtl diagtr diagbl diagbr hlslice hrslice vuslice vdslice hblur fadegrays wipetl wipetr wipebl wipebr squeezeh squeezev"`
+		Stinger  string  `yaml:"stinger" validate:"required_if=Mode stinger"`
+	} `yaml:"transition"`
+
+	Slate struct {
+		Enabled  bool `yaml:"enabled"`
+		Card     `yaml:",inline"`
+		Deadline time.Duration `yaml:"deadline" validate:"gte=0"`
+	} `yaml:"slate"`
+
+	Bumpers struct {
+		EveryClips int           `yaml:"every_clips" validate:"gte=0"`
+		Every      time.Duration `yaml:"every" validate:"gte=0"`
+		Items      []Card        `yaml:"items" validate:"required_with=EveryClips Every,dive"`
+	} `yaml:"bumpers"`
+
+	Countdown struct {
+		Enabled bool `yaml:"enabled"`
+		Card    `yaml:",inline"`
+	} `yaml:"countdown"`
+
+	Outro struct {
+		Enabled bool `yaml:"enabled"`
+		Card    `yaml:",inline"`
+	} `yaml:"outro"`
+
+	TitleCard struct {
+		Enabled  bool    `yaml:"enabled"`
+		Text     string  `yaml:"text"`
+		FontFile string  `yaml:"font_file"`
+		Duration float64 `yaml:"duration" validate:"gte=0,lte=30"`
+	} `yaml:"title_card"`
+
+	Overlay struct {
+		Layers []OverlayLayer `yaml:"layers" validate:"dive"`
+	} `yaml:"overlay"`
+
+	Render struct {
+		Output   string        `yaml:"output"`
+		Clips    int           `yaml:"clips" validate:"gte=0"`
+		Duration time.Duration `yaml:"duration" validate:"gte=0"`
+	} `yaml:"render"`
+
+	Clips struct {
+		Selector         string        `yaml:"selector" validate:"omitempty,oneof=random views velocity round_robin no_repeat chronological score"`
+		Score            string        `yaml:"score" validate:"required_if=Selector score"`
+		RefreshInterval  time.Duration `yaml:"refresh_interval"`
+		ExhaustionPolicy string        `yaml:"exhaustion_policy" validate:"omitempty,oneof=stop history reshuffle playlist"`
+		FallbackPlaylist []string      `yaml:"fallback_playlist" validate:"required_if=ExhaustionPolicy playlist"`
+		Filters          ClipFilters   `yaml:"filters"`
+	} `yaml:"clips"`
+
+	History struct {
+		Path     string        `yaml:"path"`
+		Cooldown time.Duration `yaml:"cooldown" validate:"gte=0"`
+	} `yaml:"history"`
+
+	API struct {
+		Listen string `yaml:"listen"`
+		Token  string `yaml:"token"`
+	} `yaml:"api"`
 }
 
-func Load() (*Config, error) {
+func Load(path string) (*Config, error) {
 	span := sentry.StartSpan(context.Background(), "config.load")
 	defer span.Finish()
 
-	data, err := os.ReadFile("config.yaml")
+	data, err := os.ReadFile(path)
 	if err != nil {
 		sentry.CaptureException(err)
 		return nil, fmt.Errorf("failed to read config file: %w", err)
@@ -50,12 +161,95 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse YAML config: %w", err)
 	}
 
+	if result.DataDir == "" {
+		result.DataDir = "data"
+	}
 	if result.Sentry.TracesSampleRate == 0 {
 		result.Sentry.TracesSampleRate = 1.0
 	}
 	if result.Sentry.Environment == "" {
 		result.Sentry.Environment = "production"
 	}
+	if result.Stream.PreloadCount == 0 {
+		result.Stream.PreloadCount = 5
+	}
+	if result.Stream.PreloadWorkers == 0 {
+		result.Stream.PreloadWorkers = 2
+	}
+	// a negative refresh interval turns the refresh off
+	if result.Clips.RefreshInterval == 0 {
+		result.Clips.RefreshInterval = 30 * time.Minute
+	}
+	if result.Clips.ExhaustionPolicy == "" {
+		result.Clips.ExhaustionPolicy = "history"
+	}
+	if result.History.Path == "" {
+		result.History.Path = "history.jsonl"
+	}
+	if result.API.Listen == "" {
+		result.API.Listen = "127.0.0.1:8080"
+	}
+	if result.Render.Output == "" {
+		result.Render.Output = "compilation.mp4"
+	}
+	// a compilation without a target length is ten clips long
+	if result.Render.Clips == 0 && result.Render.Duration == 0 {
+		result.Render.Clips = 10
+	}
+
+	if err := result.setupEncoding(); err != nil {
+		sentry.CaptureException(err)
+		return nil, fmt.Errorf("failed to setup encoding: %w", err)
+	}
+
+	if result.Audio.Loudnorm.TargetI == 0 {
+		result.Audio.Loudnorm.TargetI = -16
+	}
+	if result.Audio.Loudnorm.TargetTP == 0 {
+		result.Audio.Loudnorm.TargetTP = -1.5
+	}
+	if result.Audio.Loudnorm.TargetLRA == 0 {
+		result.Audio.Loudnorm.TargetLRA = 11
+	}
+
+	if result.Quality.MaxBlackRatio == 0 {
+		result.Quality.MaxBlackRatio = 0.5
+	}
+	if result.Quality.MaxFrozenRatio == 0 {
+		result.Quality.MaxFrozenRatio = 0.5
+	}
+	if result.Quality.MaxSilentRatio == 0 {
+		result.Quality.MaxSilentRatio = 0.9
+	}
+
+	if result.Trim.MaxStart == 0 {
+		result.Trim.MaxStart = 3
+	}
+	if result.Trim.MaxEnd == 0 {
+		result.Trim.MaxEnd = 5
+	}
+	if result.Trim.MinDuration == 0 {
+		result.Trim.MinDuration = 5
+	}
+
+	result.setupTransition()
+	result.setupOverlay()
+	result.setupCards()
+
+	if err := result.setupExpressions(); err != nil {
+		sentry.CaptureException(err)
+		return nil, fmt.Errorf("failed to setup expressions: %w", err)
+	}
+
+	if err := result.setupDestinations(); err != nil {
+		sentry.CaptureException(err)
+		return nil, fmt.Errorf("failed to setup destinations: %w", err)
+	}
+
+	if err := result.setupAPI(); err != nil {
+		sentry.CaptureException(err)
+		return nil, fmt.Errorf("failed to setup api: %w", err)
+	}
 
 	validate := validator.New(validator.WithRequiredStructEnabled())
 	if err := validate.Struct(result); err != nil {
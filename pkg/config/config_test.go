@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const baseConfig = `
+twitch:
+  broadcaster_ids: ["12345"]
+  game_id: "27471"
+  min_date: "December 28, 2018"
+  client_id: client_id
+  client_secret: client_secret
+`
+
+func loadConfig(t *testing.T, extra string) (*Config, error) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig+extra), 0o644))
+
+	return Load(path)
+}
+
+func TestAPIListen(t *testing.T) {
+	cfg, err := loadConfig(t, "")
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:8080", cfg.API.Listen)
+
+	tests := []struct {
+		name  string
+		api   string
+		valid bool
+	}{
+		{"loopback", "api:\n  listen: 127.0.0.1:9000\n", true},
+		{"ipv6 loopback", "api:\n  listen: \"[::1]:9000\"\n", true},
+		{"localhost", "api:\n  listen: localhost:9000\n", true},
+		{"all interfaces with token", "api:\n  listen: \":8080\"\n  token: secret\n", true},
+		{"all interfaces", "api:\n  listen: \":8080\"\n", false},
+		{"public address", "api:\n  listen: 10.0.0.1:8080\n", false},
+		{"no port", "api:\n  listen: 127.0.0.1\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(t, tt.api)
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestEncodingProfiles(t *testing.T) {
+	cfg, err := loadConfig(t, `
+encoding:
+  profile: affiliate
+  profiles:
+    affiliate:
+      width: 1280
+      height: 720
+      fps: 30
+      video_bitrate: 3000
+`)
+	require.NoError(t, err)
+
+	// omitted fields fall back to the default profile, the bitrate limits follow the profile bitrate
+	profile := cfg.ActiveProfile()
+	require.Equal(t, 1280, profile.Width)
+	require.Equal(t, 3000, profile.MaxBitrate)
+	require.Equal(t, 6000, profile.BufferSize)
+	require.Equal(t, "fast", profile.Preset)
+	require.Empty(t, profile.Tune)
+	require.Equal(t, 44100, profile.AudioRate)
+	require.Equal(t, 2, profile.AudioChannels)
+
+	tests := []struct {
+		name    string
+		profile string
+	}{
+		{"bad preset", "preset: turbo"},
+		{"bad tune", "tune: cinema"},
+		{"zero audio rate", "audio_rate: 0"},
+		{"unsupported audio rate", "audio_rate: 96000"},
+		{"zero audio channels", "audio_channels: 0"},
+		{"surround audio", "audio_channels: 6"},
+		{"odd resolution", "width: 1281"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(t, "encoding:\n  profile: custom\n  profiles:\n    custom:\n      "+tt.profile+"\n")
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := loadConfig(t, "encoding:\n  profile: missing\n")
+		require.ErrorContains(t, err, "unknown encoding profile: missing")
+	})
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Destination describes a single output the stream is pushed to
+type Destination struct {
+	Name   string `yaml:"name" validate:"required"`
+	URL    string `yaml:"url" validate:"required"`
+	Format string `yaml:"format" validate:"omitempty,oneof=flv mpegts matroska"`
+}
+
+// ResolveFormat returns the configured muxer or guesses it from the URL scheme
+func (d *Destination) ResolveFormat() string {
+	if d.Format != "" {
+		return d.Format
+	}
+
+	if parsed, err := url.Parse(d.URL); err == nil {
+		switch parsed.Scheme {
+		case "rtmp", "rtmps":
+			return "flv"
+		case "srt", "udp", "rtp", "tcp":
+			return "mpegts"
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(d.URL)) {
+	case ".flv":
+		return "flv"
+	case ".mkv":
+		return "matroska"
+	default:
+		return "mpegts"
+	}
+}
+
+func (c *Config) setupDestinations() error {
+	if c.Twitch.RTMPUrl != "" {
+		c.Stream.Destinations = append([]Destination{{
+			Name: "twitch",
+			URL:  c.Twitch.RTMPUrl,
+		}}, c.Stream.Destinations...)
+	}
+
+	names := make(map[string]struct{}, len(c.Stream.Destinations))
+	for _, destination := range c.Stream.Destinations {
+		if _, ok := names[destination.Name]; ok {
+			return fmt.Errorf("duplicate destination name: %s", destination.Name)
+		}
+		names[destination.Name] = struct{}{}
+	}
+
+	return nil
+}
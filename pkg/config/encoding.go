@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultEncodingProfile = "default"
+
+// EncodingProfile describes the output video and audio format, bitrates are in kbit/s
+type EncodingProfile struct {
+	Width            int     `yaml:"width" validate:"gt=0"`
+	Height           int     `yaml:"height" validate:"gt=0"`
+	FPS              int     `yaml:"fps" validate:"gt=0,lte=120"`
+	VideoBitrate     int     `yaml:"video_bitrate" validate:"gt=0"`
+	MaxBitrate       int     `yaml:"max_bitrate" validate:"gtefield=VideoBitrate"`
+	BufferSize       int     `yaml:"buffer_size" validate:"gt=0"`
+	KeyframeInterval float64 `yaml:"keyframe_interval" validate:"gt=0"`
+	Preset           string  `yaml:"preset" validate:"oneof=ultrafast superfast veryfast faster fast medium slow slower veryslow"`
+	Tune             string  `yaml:"tune" validate:"omitempty,oneof=film animation grain stillimage fastdecode zerolatency"`
+	H264Profile      string  `yaml:"h264_profile" validate:"oneof=baseline main high"`
+	AudioBitrate     int     `yaml:"audio_bitrate" validate:"gt=0"`
+	AudioRate        int     `yaml:"audio_rate" validate:"oneof=22050 32000 44100 48000"`
+	AudioChannels    int     `yaml:"audio_channels" validate:"oneof=1 2"`
+}
+
+func defaultEncodingProfile() EncodingProfile {
+	return EncodingProfile{
+		Width:            1920,
+		Height:           1080,
+		FPS:              60,
+		VideoBitrate:     6000,
+		MaxBitrate:       6000,
+		BufferSize:       12000,
+		KeyframeInterval: 2,
+		Preset:           "fast",
+		Tune:             "zerolatency",
+		H264Profile:      "main",
+		AudioBitrate:     160,
+		AudioRate:        44100,
+		AudioChannels:    2,
+	}
+}
+
+// UnmarshalYAML fills the omitted fields with the values of the default profile, an explicit zero is kept
+// for the validation to reject. The tune is optional and the bitrate limits follow the profile bitrate
+func (p *EncodingProfile) UnmarshalYAML(value *yaml.Node) error {
+	type plain EncodingProfile
+
+	profile := plain(defaultEncodingProfile())
+	profile.MaxBitrate = 0
+	profile.BufferSize = 0
+	profile.Tune = ""
+
+	if err := value.Decode(&profile); err != nil {
+		return err
+	}
+
+	*p = EncodingProfile(profile)
+	return nil
+}
+
+// applyDefaults derives the omitted bitrate limits from the profile bitrate
+func (p *EncodingProfile) applyDefaults() {
+	if p.MaxBitrate == 0 {
+		p.MaxBitrate = p.VideoBitrate
+	}
+	if p.BufferSize == 0 {
+		p.BufferSize = 2 * p.MaxBitrate
+	}
+}
+
+// KeyframeFrames returns the GOP size in frames
+func (p *EncodingProfile) KeyframeFrames() int {
+	return max(int(p.KeyframeInterval*float64(p.FPS)), 1)
+}
+
+// ActiveProfile returns the encoding profile selected in the config
+func (c *Config) ActiveProfile() EncodingProfile {
+	return c.Encoding.Profiles[c.Encoding.Profile]
+}
+
+func (c *Config) setupEncoding() error {
+	if c.Encoding.Profile == "" {
+		c.Encoding.Profile = DefaultEncodingProfile
+	}
+
+	if c.Encoding.Profiles == nil {
+		c.Encoding.Profiles = make(map[string]EncodingProfile)
+	}
+
+	if _, ok := c.Encoding.Profiles[DefaultEncodingProfile]; !ok {
+		c.Encoding.Profiles[DefaultEncodingProfile] = defaultEncodingProfile()
+	}
+
+	for name, profile := range c.Encoding.Profiles {
+		profile.applyDefaults()
+
+		// yuv420p requires both dimensions to be even
+		if profile.Width%2 != 0 || profile.Height%2 != 0 {
+			return fmt.Errorf("encoding profile %s: resolution %dx%d must be even", name, profile.Width, profile.Height)
+		}
+
+		c.Encoding.Profiles[name] = profile
+	}
+
+	if _, ok := c.Encoding.Profiles[c.Encoding.Profile]; !ok {
+		return fmt.Errorf("unknown encoding profile: %s", c.Encoding.Profile)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"k0pern1cus/pkg/clipexpr"
+	"time"
+)
+
+// ClipFilters decide which fetched clips are eligible for the rotation, empty fields do not filter
+type ClipFilters struct {
+	Languages        []string               `yaml:"languages"`
+	MinDuration      float64                `yaml:"min_duration" validate:"gte=0"`
+	MaxDuration      float64                `yaml:"max_duration" validate:"omitempty,gtefield=MinDuration"`
+	MinViews         int                    `yaml:"min_views" validate:"gte=0"`
+	BlockedCreators  []string               `yaml:"blocked_creators"`
+	BroadcasterDates []BroadcasterDateRange `yaml:"broadcaster_dates" validate:"dive"`
+	TitleInclude     []string               `yaml:"title_include"`
+	TitleExclude     []string               `yaml:"title_exclude"`
+	FeaturedOnly     bool                   `yaml:"featured_only"`
+	// Expression is an expression over the clip fields that has to evaluate to true
+	Expression string `yaml:"expression"`
+}
+
+// BroadcasterDateRange limits the clips of a single broadcaster to the ones created within the range,
+// both ends included. Broadcaster is the login, it is resolved into BroadcasterID on start
+type BroadcasterDateRange struct {
+	Broadcaster   string    `yaml:"broadcaster" validate:"required"`
+	From          time.Time `yaml:"from"`
+	To            time.Time `yaml:"to"`
+	BroadcasterID string    `yaml:"-"`
+}
+
+// setupExpressions compiles the clip expressions, so mistakes are reported before anything is fetched
+func (c *Config) setupExpressions() error {
+	if c.Clips.Filters.Expression != "" {
+		if _, err := clipexpr.CompileFilter(c.Clips.Filters.Expression); err != nil {
+			return fmt.Errorf("clips.filters.expression: %w", err)
+		}
+	}
+
+	if c.Clips.Score != "" {
+		if _, err := clipexpr.CompileScore(c.Clips.Score); err != nil {
+			return fmt.Errorf("clips.score: %w", err)
+		}
+	}
+
+	return nil
+}
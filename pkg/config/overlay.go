@@ -0,0 +1,60 @@
+package config
+
+const DefaultFontFile = "/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf"
+
+// OverlayLayer describes a single text drawn over the clip, Text is a Go template rendered against the clip fields
+type OverlayLayer struct {
+	Text         string  `yaml:"text" validate:"required"`
+	Position     string  `yaml:"position" validate:"omitempty,oneof=top_left top_center top_right center bottom_left bottom_center bottom_right"`
+	X            string  `yaml:"x"`
+	Y            string  `yaml:"y"`
+	Margin       int     `yaml:"margin" validate:"gte=0"`
+	FontFile     string  `yaml:"font_file"`
+	FontSize     int     `yaml:"font_size" validate:"gte=0"`
+	FontColor    string  `yaml:"font_color"`
+	ShadowColor  string  `yaml:"shadow_color"`
+	ShadowOffset int     `yaml:"shadow_offset" validate:"gte=0"`
+	Box          bool    `yaml:"box"`
+	BoxColor     string  `yaml:"box_color"`
+	BoxBorder    int     `yaml:"box_border" validate:"gte=0"`
+	ShowAt       float64 `yaml:"show_at" validate:"gte=0"`
+	HideAt       float64 `yaml:"hide_at"`
+}
+
+func defaultOverlayLayers() []OverlayLayer {
+	return []OverlayLayer{{
+		Text:         "{{.broadcaster_name}} - {{.title}}",
+		Position:     "top_right",
+		Margin:       20,
+		FontSize:     28,
+		FontColor:    "white",
+		ShadowColor:  "black",
+		ShadowOffset: 2,
+	}}
+}
+
+func (c *Config) setupOverlay() {
+	if c.Overlay.Layers == nil {
+		c.Overlay.Layers = defaultOverlayLayers()
+	}
+
+	for i := range c.Overlay.Layers {
+		layer := &c.Overlay.Layers[i]
+
+		if layer.Position == "" {
+			layer.Position = "top_right"
+		}
+		if layer.FontFile == "" {
+			layer.FontFile = DefaultFontFile
+		}
+		if layer.FontSize == 0 {
+			layer.FontSize = 28
+		}
+		if layer.FontColor == "" {
+			layer.FontColor = "white"
+		}
+		if layer.BoxColor == "" {
+			layer.BoxColor = "black@0.5"
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+const (
+	TransitionFade      = "fade"
+	TransitionCrossfade = "crossfade"
+	TransitionStinger   = "stinger"
+)
+
+func (c *Config) setupTransition() {
+	if c.Transition.Mode == "" {
+		c.Transition.Mode = TransitionFade
+	}
+
+	if c.Transition.Duration == 0 {
+		switch c.Transition.Mode {
+		case TransitionCrossfade:
+			c.Transition.Duration = 1
+		default:
+			c.Transition.Duration = 0.5
+		}
+	}
+
+	if c.Transition.Effect == "" {
+		c.Transition.Effect = "fade"
+	}
+}
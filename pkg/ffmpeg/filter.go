@@ -0,0 +1,142 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// option values are escaped first, then the whole filter description is escaped for the filtergraph,
+// see https://ffmpeg.org/ffmpeg-filters.html#Notes-on-filtergraph-escaping
+var optionEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	`:`, `\:`,
+)
+
+var graphEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`,`, `\,`,
+	`;`, `\;`,
+)
+
+// Option is a single key=value pair of a filter
+type Option struct {
+	Key   string
+	Value string
+}
+
+// Filter is a single filter of a filtergraph
+type Filter struct {
+	Name    string
+	Options []Option
+}
+
+func NewFilter(name string) *Filter {
+	return &Filter{
+		Name: name,
+	}
+}
+
+// Set appends an option, the value is escaped when the filter is rendered
+func (f *Filter) Set(key string, value any) *Filter {
+	f.Options = append(f.Options, Option{
+		Key:   key,
+		Value: formatValue(value),
+	})
+
+	return f
+}
+
+func (f *Filter) String() string {
+	if len(f.Options) == 0 {
+		return f.Name
+	}
+
+	options := make([]string, 0, len(f.Options))
+	for _, option := range f.Options {
+		options = append(options, option.Key+"="+optionEscaper.Replace(option.Value))
+	}
+
+	return graphEscaper.Replace(f.Name + "=" + strings.Join(options, ":"))
+}
+
+// Chain is a linear sequence of filters with optional input and output pad labels
+type Chain struct {
+	Inputs  []string
+	Filters []*Filter
+	Outputs []string
+}
+
+func NewChain(filters ...*Filter) *Chain {
+	return &Chain{
+		Filters: filters,
+	}
+}
+
+func (c *Chain) Append(filters ...*Filter) *Chain {
+	c.Filters = append(c.Filters, filters...)
+	return c
+}
+
+func (c *Chain) From(labels ...string) *Chain {
+	c.Inputs = append(c.Inputs, labels...)
+	return c
+}
+
+func (c *Chain) To(labels ...string) *Chain {
+	c.Outputs = append(c.Outputs, labels...)
+	return c
+}
+
+func (c *Chain) String() string {
+	var sb strings.Builder
+
+	for _, label := range c.Inputs {
+		sb.WriteString("[" + label + "]")
+	}
+
+	for i, filter := range c.Filters {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(filter.String())
+	}
+
+	for _, label := range c.Outputs {
+		sb.WriteString("[" + label + "]")
+	}
+
+	return sb.String()
+}
+
+// Graph is a complete filtergraph made of chains
+type Graph []*Chain
+
+func (g Graph) String() string {
+	chains := make([]string, 0, len(g))
+	for _, chain := range g {
+		chains = append(chains, chain.String())
+	}
+
+	return strings.Join(chains, ";")
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(v)
+	}
+}
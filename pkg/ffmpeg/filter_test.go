@@ -0,0 +1,37 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterEscaping(t *testing.T) {
+	filter := NewFilter("drawtext").
+		Set("text", "this is a 'string': may contain one, or more, special characters")
+
+	require.Equal(t,
+		`drawtext=text=this is a \\\'string\\\'\\: may contain one\, or more\, special characters`,
+		filter.String(),
+	)
+}
+
+func TestFilterValues(t *testing.T) {
+	filter := NewFilter("fade").
+		Set("t", "in").
+		Set("st", 0.0).
+		Set("d", 0.5).
+		Set("alpha", true).
+		Set("enable", "between(t,1,2)")
+
+	require.Equal(t, `fade=t=in:st=0:d=0.5:alpha=1:enable=between(t\,1\,2)`, filter.String())
+}
+
+func TestGraph(t *testing.T) {
+	graph := Graph{
+		NewChain(NewFilter("scale").Set("w", 1280).Set("h", 720)).From("0:v").To("v0"),
+		NewChain(NewFilter("anull")).From("0:a").To("a0"),
+	}
+
+	require.Equal(t, `[0:v]scale=w=1280:h=720[v0];[0:a]anull[a0]`, graph.String())
+}
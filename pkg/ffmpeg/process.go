@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// MonitorOutput forwards ffmpeg stderr to the logs and Sentry breadcrumbs until the pipe is closed
+func MonitorOutput(stderr io.ReadCloser, processType string) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		slog.Warn("FFmpeg output",
+			slog.String("process", processType),
+			slog.String("line", line),
+		)
+
+		sentry.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "ffmpeg",
+			Message:  line,
+			Data: map[string]interface{}{
+				"process_type": processType,
+			},
+			Level: sentry.LevelWarning,
+		})
+	}
+}
+
+// Run executes ffmpeg until it exits
+func Run(ctx context.Context, processType string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("create stderr pipe: %w", err)
+	}
+
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("start ffmpeg: %w", err)
+	}
+
+	MonitorOutput(stderr, processType)
+
+	if err = cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+
+	return nil
+}
+
+// Output executes ffmpeg until it exits and returns everything it wrote to stderr,
+// used for analysis filters that report their results in the log
+func Output(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return stderr.Bytes(), fmt.Errorf("ffmpeg: %w", err)
+	}
+
+	return stderr.Bytes(), nil
+}